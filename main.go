@@ -387,7 +387,7 @@ func main() {
 		// override most recent data file
 		parsedInputDate, err := time.Parse(time.RFC3339, flags.DataDate)
 		fatalIfErr(err, fmt.Sprintf("issue parsing date: %s", flags.DataDate))
-		inputConf, err := s3filepath.CreateS3File(s3filepath.S3PathChecker{}, bucket, flags.InputSchemaName, t, flags.ConfigFile, parsedInputDate)
+		inputConf, err := s3filepath.CreateS3FileContext(ctx, s3filepath.S3PathChecker{}, bucket, flags.InputSchemaName, t, flags.ConfigFile, parsedInputDate)
 		fatalIfErr(err, "Issue getting data file from s3")
 		inputTable, err := db.GetTableFromConf(*inputConf) // allow passing explicit config later
 		fatalIfErr(err, "Issue getting table from input")
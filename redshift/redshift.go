@@ -43,32 +43,18 @@ type Redshift struct {
 	user string
 }
 
-// Table is our representation of a Redshift table
-type Table struct {
-	Name    string    `yaml:"dest"`
-	Columns []ColInfo `yaml:"columns"`
-	Meta    Meta      `yaml:"meta"`
-}
+// Table is our representation of a Redshift table. It's the config file's table, so the
+// config is only modelled once, in s3filepath.
+type Table = s3filepath.Config
 
 // Meta holds information that might be not in Redshift or annoying to access
 // in this case, we want to know the schema a table is part of
 // and the column which corresponds to the timestamp at which the data was gathered
-type Meta struct {
-	DataDateColumn string `yaml:"datadatecolumn"`
-	Schema         string `yaml:"schema"`
-}
+type Meta = s3filepath.ConfigMeta
 
 // ColInfo is a struct that contains information about a column in a Redshift database.
 // SortOrdinal and DistKey only make sense for Redshift
-type ColInfo struct {
-	Name        string `yaml:"dest"`
-	Type        string `yaml:"type"`
-	DefaultVal  string `yaml:"defaultval"`
-	NotNull     bool   `yaml:"notnull"`
-	PrimaryKey  bool   `yaml:"primarykey"`
-	DistKey     bool   `yaml:"distkey"`
-	SortOrdinal int    `yaml:"sortord"`
-}
+type ColInfo = s3filepath.ColumnConfig
 
 type rangeQuery int
 
@@ -114,20 +100,6 @@ WHERE c.relkind = 'r'::char
      AND f.attnum > 0 ORDER BY f.attnum`
 )
 
-var (
-	// map between the config file and the redshift internal representations for types
-	typeMapping = map[string]string{
-		"boolean":   "boolean",
-		"float":     "double precision",
-		"int":       "integer",
-		"bigint":    "bigint",
-		"date":      "date",
-		"timestamp": "timestamp without time zone", // timestamp with timezone is not supported in redshift
-		"text":      "character varying(256)",      // unfortunately redshift turns text -> varchar 256
-		"longtext":  "character varying(65535)",    // when you actually need more than 256 characters
-	}
-)
-
 // NewRedshift returns a pointer to a new redshift object using configuration values passed in
 // on instantiation and the AWS env vars we assume exist
 // Don't need to pass s3 info unless doing a COPY operation
@@ -305,7 +277,7 @@ func getColumnSQL(c ColInfo) string {
 		distKey = "DISTKEY"
 	}
 
-	return fmt.Sprintf(" \"%s\" %s %s %s %s %s %s", c.Name, typeMapping[c.Type], defaultVal, notNull, sortKey, primaryKey, distKey)
+	return fmt.Sprintf(" \"%s\" %s %s %s %s %s %s", c.Name, c.RedshiftType(), defaultVal, notNull, sortKey, primaryKey, distKey)
 }
 
 // CreateTable runs the full create table command in the provided transaction, given a
@@ -429,11 +401,11 @@ func checkColumn(inCol ColInfo, targetCol ColInfo) error {
 	if inCol.Name != targetCol.Name {
 		errors = multierror.Append(errors, fmt.Errorf(mismatchedTemplate, inCol.Name, "Name", inCol.Name, targetCol.Name))
 	}
-	if typeMapping[inCol.Type] != targetCol.Type {
-		if strings.HasPrefix(typeMapping[inCol.Type], "character varying") && strings.HasPrefix(typeMapping[inCol.Type], "character varying") {
+	if inCol.RedshiftType() != targetCol.Type {
+		if strings.HasPrefix(inCol.RedshiftType(), "character varying") && strings.HasPrefix(inCol.RedshiftType(), "character varying") {
 			// If they are both varchars but differing values, we will ignore this
 		} else {
-			errors = multierror.Append(errors, fmt.Errorf(mismatchedTemplate, inCol.Name, "Type", typeMapping[inCol.Type], targetCol.Type))
+			errors = multierror.Append(errors, fmt.Errorf(mismatchedTemplate, inCol.Name, "Type", inCol.RedshiftType(), targetCol.Type))
 		}
 	}
 	if inCol.DefaultVal != targetCol.DefaultVal {
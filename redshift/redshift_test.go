@@ -209,10 +209,10 @@ func TestCreateTable(t *testing.T) {
 	dbTable := Table{
 		Name: table,
 		Columns: []ColInfo{
-			{"test1", "int", "100", true, false, true, 1},
-			{"id", "text", "", false, true, false, 0},
-			{"somelongtext", "longtext", "", false, false, false, 0},
-			{"test2", "bigint", "9999999999", false, false, false, 0},
+			{Name: "test1", Type: "int", DefaultVal: "100", NotNull: true, DistKey: true, SortOrdinal: 1},
+			{Name: "id", Type: "text", PrimaryKey: true},
+			{Name: "somelongtext", Type: "longtext"},
+			{Name: "test2", Type: "bigint", DefaultVal: "9999999999"},
 		},
 		Meta: Meta{Schema: schema},
 	}
@@ -251,9 +251,9 @@ func TestNoKeyCreateTable(t *testing.T) {
 	dbTable := Table{
 		Name: table,
 		Columns: []ColInfo{
-			{"test1", "int", "100", true, false, false, 0},
-			{"id", "text", "", false, false, false, 0},
-			{"somelongtext", "longtext", "", false, false, false, 0},
+			{Name: "test1", Type: "int", DefaultVal: "100", NotNull: true},
+			{Name: "id", Type: "text"},
+			{Name: "somelongtext", Type: "longtext"},
 		},
 		Meta: Meta{Schema: schema},
 	}
@@ -505,11 +505,11 @@ func TestUpdateTable(t *testing.T) {
 		Name: table,
 		// order incorrectly on purpose to ensure ordering works
 		Columns: []ColInfo{
-			{"test3", "boolean", "true", false, false, false, 0},
-			{"test2", "int", "100", true, false, true, 1},
-			{"id", "text", "", false, true, false, 0},
-			{"test4", "float", "false", false, false, false, 0},
-			{"test5", "bigint", "9999999999", false, false, false, 0},
+			{Name: "test3", Type: "boolean", DefaultVal: "true"},
+			{Name: "test2", Type: "int", DefaultVal: "100", NotNull: true, DistKey: true, SortOrdinal: 1},
+			{Name: "id", Type: "text", PrimaryKey: true},
+			{Name: "test4", Type: "float", DefaultVal: "false"},
+			{Name: "test5", Type: "bigint", DefaultVal: "9999999999"},
 		},
 		Meta: Meta{Schema: schema},
 	}
@@ -537,7 +537,7 @@ func TestUpdateTable(t *testing.T) {
 	fewerColumnsTargetTable := Table{
 		Name: table,
 		Columns: []ColInfo{
-			{"test3", "boolean", "true", false, false, false, 0},
+			{Name: "test3", Type: "boolean", DefaultVal: "true"},
 		},
 		Meta: Meta{Schema: schema},
 	}
@@ -0,0 +1,111 @@
+package s3filepath
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Mover copies and deletes files, which is all it takes to move them in S3
+type Mover interface {
+	PathChecker
+	Deleter
+	// Copy copies the file at src to dst, replacing dst if it exists
+	Copy(src, dst string) error
+}
+
+// S3Mover moves objects around S3. Copies are written with its Encryption.
+type S3Mover struct {
+	S3PathChecker
+	S3Deleter
+	Encryption
+}
+
+// NewS3Mover returns an S3Mover that writes with the bucket's encryption settings
+func NewS3Mover(bucket S3Bucket) S3Mover {
+	return S3Mover{Encryption: bucket.Encryption()}
+}
+
+// copyObjectAPI is the part of the s3 client needed for Copy
+type copyObjectAPI interface {
+	CopyObject(*s3.CopyObjectInput) (*s3.CopyObjectOutput, error)
+}
+
+// Copy copies the object at src to dst with a server side copy
+func (m S3Mover) Copy(src, dst string) error {
+	if err := m.Encryption.Validate(); err != nil {
+		return err
+	}
+	bucket, _, err := splitS3Path(dst)
+	if err != nil {
+		return err
+	}
+	client, err := s3ClientForBucket(bucket)
+	if err != nil {
+		return err
+	}
+	return copyObject(client, src, dst, m.Encryption)
+}
+
+func copyObject(client copyObjectAPI, src, dst string, encryption Encryption) error {
+	srcBucket, srcKey, err := splitS3Path(src)
+	if err != nil {
+		return err
+	}
+	dstBucket, dstKey, err := splitS3Path(dst)
+	if err != nil {
+		return err
+	}
+	sse, kmsKeyID := encryption.params()
+	_, err = client.CopyObject(&s3.CopyObjectInput{
+		Bucket:               aws.String(dstBucket),
+		Key:                  aws.String(dstKey),
+		CopySource:           aws.String(url.PathEscape(srcBucket + "/" + srcKey)),
+		ServerSideEncryption: sse,
+		SSEKMSKeyId:          kmsKeyID,
+	})
+	return err
+}
+
+// Archive moves the S3File's data file under archivePrefix in the same bucket, keeping the rest
+// of its path, e.g. s3://bucket/archivePrefix/schema/table/_data_timestamp_year=.../filename.
+// It returns an S3File for the archived data file; its ConfFile is unchanged, since the config
+// file isn't moved. It's an error if the archived file already exists, see ArchiveOverwrite.
+func (f *S3File) Archive(mover Mover, archivePrefix string) (*S3File, error) {
+	return f.archive(mover, archivePrefix, false)
+}
+
+// ArchiveOverwrite is Archive, but replaces the archived file if it already exists
+func (f *S3File) ArchiveOverwrite(mover Mover, archivePrefix string) (*S3File, error) {
+	return f.archive(mover, archivePrefix, true)
+}
+
+func (f *S3File) archive(mover Mover, archivePrefix string, overwrite bool) (*S3File, error) {
+	archivePrefix = strings.Trim(archivePrefix, "/")
+	if archivePrefix == "" {
+		return nil, fmt.Errorf("archive prefix must not be empty")
+	}
+	archived := *f
+	archived.Subfolder = path.Join(archivePrefix, f.Subfolder)
+	src, dst := f.GetDataFilename(), archived.GetDataFilename()
+
+	exists, err := fileExists(context.Background(), mover, dst)
+	if err != nil {
+		return nil, fmt.Errorf("error looking for archived file %s: %w", dst, err)
+	}
+	if exists && !overwrite {
+		return nil, fmt.Errorf("archived file %s already exists", dst)
+	}
+	if err := mover.Copy(src, dst); err != nil {
+		return nil, fmt.Errorf("error copying %s to %s: %w", src, dst, err)
+	}
+	if err := mover.Delete(src); err != nil {
+		return nil, fmt.Errorf("error deleting %s after archiving it: %w", src, err)
+	}
+	return &archived, nil
+}
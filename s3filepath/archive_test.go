@@ -0,0 +1,99 @@
+package s3filepath
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockMover moves files around a MockDeleter's existing paths
+type mockMover struct {
+	MockDeleter
+	copyErr error
+}
+
+func (mm *mockMover) FileExists(path string) bool {
+	return mm.ExistingPaths[path]
+}
+
+func (mm *mockMover) Copy(src, dst string) error {
+	if mm.copyErr != nil {
+		return mm.copyErr
+	}
+	if !mm.ExistingPaths[src] {
+		return errors.New("no such source")
+	}
+	mm.ExistingPaths[dst] = true
+	return nil
+}
+
+func TestArchive(t *testing.T) {
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json")
+	archivedPath := "s3://b/archive/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z.json"
+	m := &mockMover{MockDeleter: MockDeleter{ExistingPaths: map[string]bool{f.GetDataFilename(): true}}}
+
+	archived, err := f.Archive(m, "/archive/")
+	assert.NoError(t, err)
+	assert.Equal(t, archivedPath, archived.GetDataFilename())
+	assert.Equal(t, f.ConfFile, archived.ConfFile)
+	assert.Equal(t, map[string]bool{archivedPath: true}, m.ExistingPaths)
+
+	// the archived file exists now
+	m.ExistingPaths[f.GetDataFilename()] = true
+	_, err = f.Archive(m, "archive")
+	assert.Error(t, err)
+	assert.True(t, m.ExistingPaths[f.GetDataFilename()])
+
+	archived, err = f.ArchiveOverwrite(m, "archive")
+	assert.NoError(t, err)
+	assert.Equal(t, archivedPath, archived.GetDataFilename())
+	assert.False(t, m.ExistingPaths[f.GetDataFilename()])
+
+	// the original isn't deleted if the copy fails
+	m.ExistingPaths = map[string]bool{f.GetDataFilename(): true}
+	m.copyErr = errors.New("access denied")
+	_, err = f.Archive(m, "archive")
+	assert.Error(t, err)
+	assert.True(t, m.ExistingPaths[f.GetDataFilename()])
+
+	_, err = f.Archive(m, "/")
+	assert.Error(t, err)
+}
+
+type mockCopyObject struct {
+	input *s3.CopyObjectInput
+}
+
+func (m *mockCopyObject) CopyObject(input *s3.CopyObjectInput) (*s3.CopyObjectOutput, error) {
+	m.input = input
+	return &s3.CopyObjectOutput{}, nil
+}
+
+func TestCopyObject(t *testing.T) {
+	m := &mockCopyObject{}
+	assert.NoError(t, copyObject(m, "s3://b/s/t/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z.json", "s3://other/archive/s_t.json",
+		Encryption{}))
+	assert.Equal(t, "other", aws.StringValue(m.input.Bucket))
+	assert.Equal(t, "archive/s_t.json", aws.StringValue(m.input.Key))
+	assert.Equal(t, "b%2Fs%2Ft%2F_data_timestamp_day=10%2Fs_t_2015-11-10T23:00:00Z.json", aws.StringValue(m.input.CopySource))
+
+	assert.Nil(t, m.input.ServerSideEncryption)
+	assert.Nil(t, m.input.SSEKMSKeyId)
+
+	assert.Error(t, copyObject(m, "b/key", "s3://other/key", Encryption{}))
+
+	encryption := S3Bucket{Name: "b", SSEMode: SSEModeKMS, KMSKeyID: "key-id"}.Encryption()
+	assert.NoError(t, copyObject(m, "s3://b/key", "s3://other/key", encryption))
+	assert.Equal(t, "aws:kms", aws.StringValue(m.input.ServerSideEncryption))
+	assert.Equal(t, "key-id", aws.StringValue(m.input.SSEKMSKeyId))
+}
+
+func TestS3MoverEncryption(t *testing.T) {
+	m := NewS3Mover(S3Bucket{Name: "b", SSEMode: SSEModeAES256, KMSKeyID: "key-id"})
+	assert.Equal(t, Encryption{SSEMode: SSEModeAES256, KMSKeyID: "key-id"}, m.Encryption)
+	// it's rejected before making any requests
+	assert.EqualError(t, m.Copy("s3://b/a", "s3://b/b"), `KMSKeyID can only be used with SSEMode "aws:kms"`)
+}
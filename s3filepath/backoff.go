@@ -0,0 +1,74 @@
+package s3filepath
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// BackoffStrategy decides how long RetryingPathChecker waits before retrying a failed lookup
+type BackoffStrategy interface {
+	// NextDelay returns the wait after the attempt'th failure, counting from 1
+	NextDelay(attempt int) time.Duration
+}
+
+// ConstantBackoff waits the same Delay after every failure
+type ConstantBackoff struct {
+	Delay time.Duration
+}
+
+// NextDelay returns Delay
+func (b ConstantBackoff) NextDelay(attempt int) time.Duration {
+	return b.Delay
+}
+
+// ExponentialBackoff waits Base after the first failure and doubles the wait after each one
+// after that, up to Max. A Max of zero or less doesn't cap the wait.
+type ExponentialBackoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// NextDelay returns Base * 2^(attempt-1), capped at Max
+func (b ExponentialBackoff) NextDelay(attempt int) time.Duration {
+	delay := b.Base
+	for i := 1; i < attempt; i++ {
+		if b.Max > 0 && delay >= b.Max {
+			break
+		}
+		if delay > math.MaxInt64/2 {
+			// doubling again would overflow
+			break
+		}
+		delay *= 2
+	}
+	if b.Max > 0 && delay > b.Max {
+		return b.Max
+	}
+	return delay
+}
+
+// JitteredBackoff waits a random time between zero and what ExponentialBackoff would, so
+// checkers that failed at the same moment (e.g. when S3 throttled them all) don't retry in lockstep
+type JitteredBackoff struct {
+	ExponentialBackoff
+	random func(n int64) int64 // for testing
+}
+
+// NewJitteredBackoff returns a JitteredBackoff with the given ExponentialBackoff Base and Max
+func NewJitteredBackoff(base, max time.Duration) JitteredBackoff {
+	return JitteredBackoff{ExponentialBackoff: ExponentialBackoff{Base: base, Max: max}, random: rand.Int63n}
+}
+
+// NextDelay returns a random wait in [0, ExponentialBackoff's delay)
+func (b JitteredBackoff) NextDelay(attempt int) time.Duration {
+	delay := b.ExponentialBackoff.NextDelay(attempt)
+	if delay <= 0 {
+		return 0
+	}
+	random := b.random
+	if random == nil {
+		random = rand.Int63n
+	}
+	return time.Duration(random(int64(delay)))
+}
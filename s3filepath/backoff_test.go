@@ -0,0 +1,53 @@
+package s3filepath
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/assert"
+)
+
+func delays(b BackoffStrategy, attempts int) []time.Duration {
+	var d []time.Duration
+	for attempt := 1; attempt <= attempts; attempt++ {
+		d = append(d, b.NextDelay(attempt))
+	}
+	return d
+}
+
+func TestBackoffStrategies(t *testing.T) {
+	assert.Equal(t, []time.Duration{time.Second, time.Second, time.Second}, delays(ConstantBackoff{Delay: time.Second}, 3))
+
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second},
+		delays(ExponentialBackoff{Base: time.Second}, 4))
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second},
+		delays(ExponentialBackoff{Base: time.Second, Max: 3 * time.Second}, 4))
+	// no overflow however many attempts there are
+	assert.True(t, ExponentialBackoff{Base: time.Second}.NextDelay(100) > 0)
+
+	// the random part is the full range up to the exponential delay
+	jittered := NewJitteredBackoff(time.Second, 0)
+	jittered.random = func(n int64) int64 { return n - 1 }
+	assert.Equal(t, []time.Duration{time.Second - 1, 2*time.Second - 1, 4*time.Second - 1}, delays(jittered, 3))
+	jittered.random = func(n int64) int64 { return 0 }
+	assert.Equal(t, []time.Duration{0, 0}, delays(jittered, 2))
+	for _, d := range delays(NewJitteredBackoff(time.Second, 2*time.Second), 10) {
+		assert.True(t, d >= 0 && d < 2*time.Second, d.String())
+	}
+	assert.Equal(t, time.Duration(0), JitteredBackoff{}.NextDelay(1))
+}
+
+func TestRetryingPathCheckerWithBackoff(t *testing.T) {
+	throttled := awserr.NewRequestFailure(awserr.New("SlowDown", "slow down", nil), 503, "id")
+	inner := NewInMemoryPathChecker("s3://b/k")
+	inner.Fail(throttled, throttled, throttled, throttled, throttled)
+	rp := NewRetryingPathCheckerWithBackoff(inner, 3, ConstantBackoff{Delay: time.Minute})
+	var sleeps []time.Duration
+	rp.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+
+	_, err := rp.FileExistsErr("s3://b/k")
+	assert.Equal(t, throttled, err)
+	assert.Equal(t, 3, len(inner.Checked()))
+	assert.Equal(t, []time.Duration{time.Minute, time.Minute}, sleeps)
+}
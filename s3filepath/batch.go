@@ -0,0 +1,91 @@
+package s3filepath
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+// DefaultBatchConcurrency is how many lookups CreateS3Files runs at once
+const DefaultBatchConcurrency = 10
+
+// FileRequest holds the arguments to CreateS3File for one table and date
+type FileRequest struct {
+	Schema       string
+	Table        string
+	SuppliedConf string
+	Date         time.Time
+}
+
+// CreateS3Files runs CreateS3File for each request, DefaultBatchConcurrency at a time.
+// See CreateS3FilesConcurrently.
+func CreateS3Files(pc PathChecker, bucket S3Bucket, requests []FileRequest) ([]*S3File, []error) {
+	return CreateS3FilesConcurrently(pc, bucket, requests, DefaultBatchConcurrency)
+}
+
+// CreateS3FilesConcurrently runs CreateS3File for each request using at most concurrency goroutines.
+// The results line up with the requests: for each index either the file or the error is set.
+// The PathChecker is shared between the goroutines, so it must be safe for concurrent use.
+func CreateS3FilesConcurrently(pc PathChecker, bucket S3Bucket, requests []FileRequest,
+	concurrency int) ([]*S3File, []error) {
+	files := make([]*S3File, len(requests))
+	errs := make([]error, len(requests))
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				r := requests[i]
+				files[i], errs[i] = CreateS3File(pc, bucket, r.Schema, r.Table, r.SuppliedConf, r.Date)
+			}
+		}()
+	}
+	for i := range requests {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return files, errs
+}
+
+// ResolveRange runs CreateS3File for each day from from to to, inclusive, DefaultBatchConcurrency
+// days at a time. It returns the files found and the days without a file, both in date order.
+// The time of day of from is kept for every date. Lookups that fail for any other reason than
+// the file not being there are all returned in the error, with no files or days.
+func ResolveRange(pc PathChecker, bucket S3Bucket, schema, table, conf string, from, to time.Time) ([]*S3File,
+	[]time.Time, error) {
+	if err := validateNames(schema, table); err != nil {
+		return nil, nil, err
+	}
+	var requests []FileRequest
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		requests = append(requests, FileRequest{Schema: schema, Table: table, SuppliedConf: conf, Date: date})
+	}
+	files, errs := CreateS3Files(pc, bucket, requests)
+
+	var found []*S3File
+	var missing []time.Time
+	var lookupErrs error
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			found = append(found, files[i])
+		case errors.Is(err, ErrFileNotFound):
+			missing = append(missing, requests[i].Date)
+		default:
+			lookupErrs = multierror.Append(lookupErrs, err)
+		}
+	}
+	if lookupErrs != nil {
+		return nil, nil, lookupErrs
+	}
+	return found, missing, nil
+}
@@ -0,0 +1,131 @@
+package s3filepath
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/stretchr/testify/assert"
+)
+
+// concurrentPathChecker is an InMemoryPathChecker that tracks how many lookups are running at once
+type concurrentPathChecker struct {
+	*InMemoryPathChecker
+
+	mu      sync.Mutex
+	running int
+	maxSeen int
+}
+
+func newConcurrentPathChecker() *concurrentPathChecker {
+	return &concurrentPathChecker{InMemoryPathChecker: NewInMemoryPathChecker()}
+}
+
+func (cp *concurrentPathChecker) FileExistsErr(path string) (bool, error) {
+	cp.mu.Lock()
+	cp.running++
+	if cp.running > cp.maxSeen {
+		cp.maxSeen = cp.running
+	}
+	cp.mu.Unlock()
+
+	time.Sleep(time.Millisecond)
+
+	cp.mu.Lock()
+	cp.running--
+	cp.mu.Unlock()
+	return cp.InMemoryPathChecker.FileExistsErr(path)
+}
+
+func (cp *concurrentPathChecker) FileExists(path string) bool {
+	exists, _ := cp.FileExistsErr(path)
+	return exists
+}
+
+func TestCreateS3Files(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	pc := newConcurrentPathChecker()
+	var requests []FileRequest
+	for i := 0; i < 20; i++ {
+		table := fmt.Sprintf("t%d", i)
+		requests = append(requests, FileRequest{Schema: "s", Table: table, Date: expectedDate})
+		// every third table is missing
+		if i%3 != 0 {
+			f := buildS3File(bucket, "s", table, "", expectedDate, "json")
+			pc.Add(f.GetDataFilename())
+		}
+	}
+
+	files, errs := CreateS3FilesConcurrently(pc, bucket, requests, 4)
+	assert.Len(t, files, len(requests))
+	assert.Len(t, errs, len(requests))
+	for i, r := range requests {
+		if i%3 == 0 {
+			assert.Nil(t, files[i])
+			assert.True(t, errors.Is(errs[i], ErrFileNotFound))
+			continue
+		}
+		assert.NoError(t, errs[i])
+		assert.Equal(t, r.Table, files[i].Table)
+	}
+	assert.True(t, pc.maxSeen <= 4, "ran %d lookups at once", pc.maxSeen)
+	assert.True(t, pc.maxSeen > 1, "lookups didn't run concurrently")
+
+	files, errs = CreateS3Files(pc, bucket, nil)
+	assert.Empty(t, files)
+	assert.Empty(t, errs)
+}
+
+func TestResolveRange(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	from := expectedDate.AddDate(0, 0, -5)
+	pc := newConcurrentPathChecker()
+	// days 0, 1, 3 and 5 have data, 2 and 4 don't
+	for _, day := range []int{0, 1, 3, 5} {
+		f := buildS3File(bucket, "s", "t", "", from.AddDate(0, 0, day), "json.gz")
+		pc.Add(f.GetDataFilename())
+	}
+
+	files, missing, err := ResolveRange(pc, bucket, "s", "t", "", from, expectedDate)
+	assert.NoError(t, err)
+	if assert.Len(t, files, 4) {
+		for i, day := range []int{0, 1, 3, 5} {
+			assert.True(t, from.AddDate(0, 0, day).Equal(files[i].DataDate), "file %d", i)
+		}
+	}
+	assert.Equal(t, []time.Time{from.AddDate(0, 0, 2), from.AddDate(0, 0, 4)}, missing)
+
+	files, missing, err = ResolveRange(pc, bucket, "s", "t", "", expectedDate, from)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+	assert.Empty(t, missing)
+
+	_, _, err = ResolveRange(pc, bucket, "s", "t t", "", from, expectedDate)
+	assert.Error(t, err)
+}
+
+// failingPathChecker fails every lookup
+type failingPathChecker struct {
+	err error
+}
+
+func (fp failingPathChecker) FileExists(path string) bool {
+	return false
+}
+
+func (fp failingPathChecker) FileExistsErr(path string) (bool, error) {
+	return false, fp.err
+}
+
+func TestResolveRangeErrors(t *testing.T) {
+	_, _, err := ResolveRange(failingPathChecker{errors.New("access denied")}, S3Bucket{Name: "b"}, "s", "t", "",
+		expectedDate.AddDate(0, 0, -3), expectedDate)
+	var merr *multierror.Error
+	if assert.True(t, errors.As(err, &merr)) {
+		assert.Len(t, merr.Errors, 4)
+	}
+	assert.Contains(t, err.Error(), "access denied")
+}
@@ -0,0 +1,115 @@
+package s3filepath
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// ChecksumAlgo is a hash algorithm data files can be checked with
+type ChecksumAlgo string
+
+// The supported checksum algorithms
+const (
+	ChecksumMD5    ChecksumAlgo = "md5"
+	ChecksumSHA256 ChecksumAlgo = "sha256"
+)
+
+// newHash returns a new hash for the algorithm
+func (a ChecksumAlgo) newHash() (hash.Hash, error) {
+	switch a {
+	case ChecksumMD5:
+		return md5.New(), nil
+	case ChecksumSHA256:
+		return sha256.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm %q", string(a))
+	}
+}
+
+// VerifyChecksum reads the data file and returns whether its checksum with the algorithm matches
+// expected, a hex digest (in either case). The file is streamed through the hash with the S3File's
+// read buffer (see DefaultReadBufferSize) rather than read into memory, so it works for files
+// of any size, but it does download the whole file.
+func (f *S3File) VerifyChecksum(reader ReaderProvider, expected string, algo ChecksumAlgo) (bool, error) {
+	h, err := algo.newHash()
+	if err != nil {
+		return false, err
+	}
+	dataPath := f.GetDataFilename()
+	r, err := reader.Reader(dataPath)
+	if err != nil {
+		return false, fmt.Errorf("error opening %s: %w", dataPath, err)
+	}
+	defer r.Close()
+	if _, err := io.CopyBuffer(h, onlyReader{r}, f.readBuffer()); err != nil {
+		return false, fmt.Errorf("error reading %s: %w", dataPath, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)) == strings.ToLower(strings.TrimSpace(expected)), nil
+}
+
+// sidecarAlgos are the algorithms ReadSidecarChecksum looks for sidecars of, in order
+var sidecarAlgos = []ChecksumAlgo{ChecksumMD5, ChecksumSHA256}
+
+// ErrSidecarNotFound matches (with errors.Is) the error ReadSidecarChecksum returns when
+// there's no checksum sidecar for the data file
+var ErrSidecarNotFound = errors.New("checksum sidecar not found")
+
+// ChecksumSidecarPath returns where the MD5 checksum of the data file is expected, next to it
+// with an extra .md5, e.g. s3://bucket/.../schema_table_date.json.gz.md5. Sidecars with other
+// algorithms have the algorithm's name as the extension instead, e.g. .sha256.
+func (f *S3File) ChecksumSidecarPath() string {
+	return f.checksumSidecarPath(ChecksumMD5)
+}
+
+func (f *S3File) checksumSidecarPath(algo ChecksumAlgo) string {
+	return f.GetDataFilename() + "." + string(algo)
+}
+
+// ReadSidecarChecksum reads the data file's checksum sidecar, trying the .md5 one and then the
+// .sha256 one, and returns the digest in it along with the algorithm. The sidecar may be in
+// md5sum's format, with the filename after the digest. It returns an error matching
+// ErrSidecarNotFound if there's no sidecar.
+func (f *S3File) ReadSidecarChecksum(reader ReaderProvider) (string, ChecksumAlgo, error) {
+	for _, algo := range sidecarAlgos {
+		sidecarPath := f.checksumSidecarPath(algo)
+		r, err := reader.Reader(sidecarPath)
+		if err != nil {
+			if isNotFound(err) {
+				continue
+			}
+			return "", "", fmt.Errorf("error opening %s: %w", sidecarPath, err)
+		}
+		defer r.Close()
+		// a digest is at most 64 characters, anything after the first line is ignored
+		content, err := ioutil.ReadAll(io.LimitReader(r, 1024))
+		if err != nil {
+			return "", "", fmt.Errorf("error reading %s: %w", sidecarPath, err)
+		}
+		fields := strings.Fields(string(content))
+		h, _ := algo.newHash()
+		if len(fields) == 0 || len(fields[0]) != hex.EncodedLen(h.Size()) {
+			return "", "", fmt.Errorf("%s doesn't start with a %s digest", sidecarPath, algo)
+		}
+		if _, err := hex.DecodeString(fields[0]); err != nil {
+			return "", "", fmt.Errorf("%s doesn't start with a %s digest", sidecarPath, algo)
+		}
+		return strings.ToLower(fields[0]), algo, nil
+	}
+	return "", "", fmt.Errorf("%w for %s", ErrSidecarNotFound, f.GetDataFilename())
+}
+
+// VerifySidecarChecksum is VerifyChecksum with the digest and algorithm from ReadSidecarChecksum
+func (f *S3File) VerifySidecarChecksum(reader ReaderProvider) (bool, error) {
+	expected, algo, err := f.ReadSidecarChecksum(reader)
+	if err != nil {
+		return false, err
+	}
+	return f.VerifyChecksum(reader, expected, algo)
+}
@@ -0,0 +1,98 @@
+package s3filepath
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyChecksum(t *testing.T) {
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json")
+	reader := mockReaderProvider{content: "hello world\n"}
+
+	for _, test := range []struct {
+		algo     ChecksumAlgo
+		expected string
+	}{
+		{ChecksumMD5, "6f5902ac237024bdd0c176cb93063dc4"},
+		{ChecksumMD5, "6F5902AC237024BDD0C176CB93063DC4\n"},
+		{ChecksumSHA256, "a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447"},
+	} {
+		ok, err := f.VerifyChecksum(reader, test.expected, test.algo)
+		assert.NoError(t, err)
+		assert.True(t, ok, "%s %s", test.algo, test.expected)
+	}
+
+	ok, err := f.VerifyChecksum(reader, "d41d8cd98f00b204e9800998ecf8427e", ChecksumMD5)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = f.VerifyChecksum(reader, "6f5902ac237024bdd0c176cb93063dc4", ChecksumSHA256)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = f.VerifyChecksum(reader, "abc", ChecksumAlgo("crc32"))
+	assert.EqualError(t, err, `unsupported checksum algorithm "crc32"`)
+	_, err = f.VerifyChecksum(mockReaderProvider{err: errors.New("denied")}, "abc", ChecksumMD5)
+	assert.Error(t, err)
+}
+
+// mapReaderProvider serves files from a map, missing ones like os.Open would
+type mapReaderProvider map[string]string
+
+func (m mapReaderProvider) Reader(path string) (io.ReadCloser, error) {
+	content, ok := m[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.NopCloser(strings.NewReader(content)), nil
+}
+
+func TestReadSidecarChecksum(t *testing.T) {
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json.gz")
+	dataPath := f.GetDataFilename()
+	assert.Equal(t, dataPath+".md5", f.ChecksumSidecarPath())
+
+	md5Digest := "6f5902ac237024bdd0c176cb93063dc4"
+	sha256Digest := "a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447"
+
+	// md5sum's output format
+	reader := mapReaderProvider{dataPath: "hello world\n", dataPath + ".md5": strings.ToUpper(md5Digest) + "  s_t.json.gz\n"}
+	digest, algo, err := f.ReadSidecarChecksum(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, md5Digest, digest)
+	assert.Equal(t, ChecksumMD5, algo)
+	ok, err := f.VerifySidecarChecksum(reader)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	reader = mapReaderProvider{dataPath: "hello world\n", dataPath + ".sha256": sha256Digest}
+	digest, algo, err = f.ReadSidecarChecksum(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, sha256Digest, digest)
+	assert.Equal(t, ChecksumSHA256, algo)
+
+	// a truncated upload doesn't match
+	reader[dataPath] = "hello"
+	ok, err = f.VerifySidecarChecksum(reader)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, _, err = f.ReadSidecarChecksum(mapReaderProvider{dataPath: "hello world\n"})
+	assert.True(t, errors.Is(err, ErrSidecarNotFound))
+	_, err = f.VerifySidecarChecksum(mapReaderProvider{dataPath: "hello world\n"})
+	assert.True(t, errors.Is(err, ErrSidecarNotFound))
+
+	// an md5 sidecar with a sha256 in it is malformed
+	_, _, err = f.ReadSidecarChecksum(mapReaderProvider{dataPath + ".md5": sha256Digest})
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrSidecarNotFound))
+	_, _, err = f.ReadSidecarChecksum(mapReaderProvider{dataPath + ".md5": ""})
+	assert.Error(t, err)
+	_, _, err = f.ReadSidecarChecksum(mockReaderProvider{err: errors.New("denied")})
+	assert.False(t, errors.Is(err, ErrSidecarNotFound))
+}
@@ -0,0 +1,320 @@
+package s3filepath
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	multierror "github.com/hashicorp/go-multierror"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// map between the config file types and the types Redshift reports for them,
+// which the redshift package uses too, through ColumnConfig.RedshiftType
+var redshiftTypes = map[string]string{
+	"boolean":   "boolean",
+	"float":     "double precision",
+	"int":       "integer",
+	"bigint":    "bigint",
+	"date":      "date",
+	"timestamp": "timestamp without time zone",
+	"text":      "character varying(256)",
+	"longtext":  "character varying(65535)",
+}
+
+// Config is one table's entry in a config file (the S3File's ConfFile). Config files are
+// yaml maps of tables, keyed by any name. The redshift package reads them into this same type,
+// as redshift.Table:
+//
+//	mytable:
+//	  dest: mytable
+//	  columns:
+//	    - dest: id
+//	      type: text
+//	      notnull: true
+//	      jsonpath: $.user.id
+//	  meta:
+//	    datadatecolumn: time
+//	    schema: myschema
+type Config struct {
+	Name    string         `yaml:"dest"`
+	Columns []ColumnConfig `yaml:"columns"`
+	Meta    ConfigMeta     `yaml:"meta"`
+}
+
+// ColumnConfig is a column in a Config
+type ColumnConfig struct {
+	Name string `yaml:"dest"`
+	// Type is either one of the config types (text, int, timestamp, ...) or a Redshift type
+	Type       string `yaml:"type"`
+	DefaultVal string `yaml:"defaultval"`
+	NotNull    bool   `yaml:"notnull"`
+	PrimaryKey bool   `yaml:"primarykey"`
+	DistKey    bool   `yaml:"distkey"`
+	// SortOrdinal is the column's position in the sort key, starting at 1. 0 means it's not in it.
+	SortOrdinal int `yaml:"sortord"`
+	// JSONPath is the JSONPath expression that selects the column from each JSON record,
+	// e.g. $.user.id. Defaults to the top level field with the column's name.
+	JSONPath string `yaml:"jsonpath,omitempty"`
+}
+
+// RedshiftType returns the Redshift type for the column's config type.
+// Types that aren't config types are assumed to already be Redshift types.
+func (c ColumnConfig) RedshiftType() string {
+	if t, ok := redshiftTypes[c.Type]; ok {
+		return t
+	}
+	return c.Type
+}
+
+// ConfigMeta is the table information in a Config that isn't about its columns
+type ConfigMeta struct {
+	DataDateColumn string `yaml:"datadatecolumn"`
+	Schema         string `yaml:"schema"`
+}
+
+// ParseConfig parses a config file and returns the config for the table whose dest is table.
+// If table is empty, the config file must have exactly one table.
+func ParseConfig(data []byte, table string) (*Config, error) {
+	var configs map[string]Config
+	if err := yaml.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("could not parse config: %s", err)
+	}
+	if table == "" {
+		if len(configs) != 1 {
+			return nil, fmt.Errorf("config has %d tables, expected exactly one", len(configs))
+		}
+		for _, config := range configs {
+			return &config, nil
+		}
+	}
+	for _, config := range configs {
+		if config.Name == table {
+			return &config, nil
+		}
+	}
+	return nil, fmt.Errorf("can't find table %s in config", table)
+}
+
+// LoadConfig reads a config file with exactly one table in it. Use ParseConfig to pick
+// a table out of a config file with several.
+func LoadConfig(reader io.Reader) (*Config, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("could not read config: %s", err)
+	}
+	return ParseConfig(data, "")
+}
+
+// ColumnNames returns the names of the config's columns in order, e.g. for CopyOptions.Columns
+func (c *Config) ColumnNames() []string {
+	names := make([]string, 0, len(c.Columns))
+	for _, col := range c.Columns {
+		names = append(names, col.Name)
+	}
+	return names
+}
+
+// ReadConfig reads the S3File's ConfFile with the reader, so callers don't need pathio to get at it.
+// A missing config file is an error matching ErrConfigNotFound. Pass the bytes to ParseConfig
+// with the table name to get the table's Config.
+func (f *S3File) ReadConfig(reader ReaderProvider) ([]byte, error) {
+	if f.ConfFile == "" {
+		return nil, fmt.Errorf("%w: no config file set for s3 file %s", ErrConfigNotFound, f.GetDataFilename())
+	}
+	r, err := reader.Reader(f.ConfFile)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, fmt.Errorf("%w: %s for s3 file %s", ErrConfigNotFound, f.ConfFile, f.GetDataFilename())
+		}
+		return nil, fmt.Errorf("error opening config file %s: %w", f.ConfFile, err)
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file %s: %w", f.ConfFile, err)
+	}
+	return data, nil
+}
+
+// WriteConfig writes the config to the S3File's ConfFile as YAML, in the same layout ParseConfig
+// and LoadConfig read, e.g. to save a config inferred from a sample of the data.
+// It's an error if the config file already exists, see WriteConfigOverwrite; to check for it
+// w must also be a PathChecker, like S3Writer.
+func (f *S3File) WriteConfig(w Writer, cfg *Config) error {
+	return f.writeConfig(w, cfg, false)
+}
+
+// WriteConfigOverwrite is WriteConfig, but replaces the config file if it already exists
+func (f *S3File) WriteConfigOverwrite(w Writer, cfg *Config) error {
+	return f.writeConfig(w, cfg, true)
+}
+
+func (f *S3File) writeConfig(w Writer, cfg *Config, overwrite bool) error {
+	if cfg.Name == "" {
+		return fmt.Errorf("config to write to %s has no dest table", f.ConfFile)
+	}
+	if !overwrite {
+		pc, ok := w.(PathChecker)
+		if !ok {
+			return fmt.Errorf("can't tell whether config file %s already exists with a %T, use WriteConfigOverwrite",
+				f.ConfFile, w)
+		}
+		exists, err := fileExists(context.Background(), pc, f.ConfFile)
+		if err != nil {
+			return fmt.Errorf("error looking for config file %s: %w", f.ConfFile, err)
+		}
+		if exists {
+			return fmt.Errorf("config file %s already exists", f.ConfFile)
+		}
+	}
+	data, err := yaml.Marshal(map[string]*Config{cfg.Name: cfg})
+	if err != nil {
+		return fmt.Errorf("could not marshal config: %s", err)
+	}
+	if err := w.Write(f.ConfFile, data); err != nil {
+		return fmt.Errorf("error writing config file %s: %w", f.ConfFile, err)
+	}
+	return nil
+}
+
+// ColumnDef is a column as it actually is in a Redshift table
+type ColumnDef struct {
+	Name string
+	// Type is the type as Redshift reports it, e.g. "character varying(256)"
+	Type       string
+	DefaultVal string
+	NotNull    bool
+}
+
+// ValidateColumns compares the config's columns with the table's actual columns, so mismatches
+// are caught before a COPY silently loads data into the wrong columns. Every difference is
+// reported, one line each and diff style: lines starting with "+" are columns in the config
+// but not the table, "-" are columns in the table but not the config, and "~" are columns
+// whose type, nullability or default differ.
+// Like the redshift package, varchars of different lengths aren't treated as different types.
+func (c *Config) ValidateColumns(actual []ColumnDef) error {
+	var errors error
+	actualByName := map[string]ColumnDef{}
+	for _, col := range actual {
+		actualByName[col.Name] = col
+	}
+	configNames := map[string]bool{}
+	for _, col := range c.Columns {
+		configNames[col.Name] = true
+		target, ok := actualByName[col.Name]
+		if !ok {
+			errors = multierror.Append(errors, fmt.Errorf("+ %s %s: in the config but not the table", col.Name, col.RedshiftType()))
+			continue
+		}
+		if t := col.RedshiftType(); t != target.Type && !(isVarchar(t) && isVarchar(target.Type)) {
+			errors = multierror.Append(errors, fmt.Errorf("~ %s: type is %s in the config, %s in the table", col.Name, t, target.Type))
+		}
+		if col.NotNull != target.NotNull {
+			errors = multierror.Append(errors, fmt.Errorf("~ %s: notnull is %t in the config, %t in the table", col.Name, col.NotNull, target.NotNull))
+		}
+		if col.DefaultVal != target.DefaultVal {
+			errors = multierror.Append(errors, fmt.Errorf("~ %s: default is %q in the config, %q in the table", col.Name, col.DefaultVal, target.DefaultVal))
+		}
+	}
+	for _, col := range actual {
+		if !configNames[col.Name] {
+			errors = multierror.Append(errors, fmt.Errorf("- %s %s: in the table but not the config", col.Name, col.Type))
+		}
+	}
+	return errors
+}
+
+// CreateTableSQL returns the CREATE TABLE IF NOT EXISTS statement for the config's columns,
+// with the distkey and sortkey as table attributes if any columns are in them:
+//
+//	CREATE TABLE IF NOT EXISTS "schema"."table" ("id" character varying(256) NOT NULL PRIMARY KEY,
+//	  "time" timestamp without time zone) DISTKEY("id") SORTKEY("time")
+func (c *Config) CreateTableSQL(schema, table string) (string, error) {
+	if len(c.Columns) == 0 {
+		return "", fmt.Errorf("config for table %s has no columns", c.Name)
+	}
+	var columns []string
+	var distKey string
+	sortKeys := map[int]string{}
+	for _, col := range c.Columns {
+		if col.Type == "" {
+			return "", fmt.Errorf("column %s has no type", col.Name)
+		}
+		parts := []string{quoteIdent(col.Name), col.RedshiftType()}
+		if col.DefaultVal != "" {
+			parts = append(parts, "DEFAULT "+col.DefaultVal)
+		}
+		if col.NotNull {
+			parts = append(parts, "NOT NULL")
+		}
+		if col.PrimaryKey {
+			parts = append(parts, "PRIMARY KEY")
+		}
+		columns = append(columns, strings.Join(parts, " "))
+
+		if col.DistKey {
+			if distKey != "" {
+				return "", fmt.Errorf("only one distkey is allowed, found %s and %s", distKey, col.Name)
+			}
+			distKey = col.Name
+		}
+		if col.SortOrdinal != 0 {
+			if other, ok := sortKeys[col.SortOrdinal]; ok {
+				return "", fmt.Errorf("columns %s and %s both have sort ordinal %d", other, col.Name, col.SortOrdinal)
+			}
+			sortKeys[col.SortOrdinal] = col.Name
+		}
+	}
+
+	sql := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", quoteIdent(schema)+"."+quoteIdent(table), strings.Join(columns, ", "))
+	if distKey != "" {
+		sql += fmt.Sprintf(" DISTKEY(%s)", quoteIdent(distKey))
+	}
+	if len(sortKeys) > 0 {
+		var sortColumns []string
+		for i := 1; i <= len(sortKeys); i++ {
+			name, ok := sortKeys[i]
+			if !ok {
+				return "", fmt.Errorf("sort ordinals must run from 1 to %d, %d is missing", len(sortKeys), i)
+			}
+			sortColumns = append(sortColumns, quoteIdent(name))
+		}
+		sql += fmt.Sprintf(" SORTKEY(%s)", strings.Join(sortColumns, ", "))
+	}
+	return sql, nil
+}
+
+func isVarchar(t string) bool {
+	return strings.HasPrefix(t, "character varying")
+}
+
+// jsonPaths is a Redshift JSONPaths file
+// See: https://docs.aws.amazon.com/redshift/latest/dg/copy-parameters-data-format.html#copy-json-jsonpaths
+type jsonPaths struct {
+	JSONPaths []string `json:"jsonpaths"`
+}
+
+// GenerateJSONPaths returns the JSONPaths file for the config, with one expression per column
+// in column order, since that's how COPY matches them up. Upload it next to the data and pass
+// its path as CopyOptions.JSONPaths.
+func GenerateJSONPaths(cfg Config) ([]byte, error) {
+	if len(cfg.Columns) == 0 {
+		return nil, fmt.Errorf("config for table %s has no columns", cfg.Name)
+	}
+	paths := jsonPaths{JSONPaths: make([]string, 0, len(cfg.Columns))}
+	for _, col := range cfg.Columns {
+		p := col.JSONPath
+		if p == "" {
+			p = fmt.Sprintf("$['%s']", col.Name)
+		}
+		if !strings.HasPrefix(p, "$") {
+			return nil, fmt.Errorf("jsonpath %q for column %s must start with $", p, col.Name)
+		}
+		paths.JSONPaths = append(paths.JSONPaths, p)
+	}
+	return json.Marshal(paths)
+}
@@ -0,0 +1,231 @@
+package s3filepath
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sampleConfig = `
+events:
+  dest: events
+  columns:
+    - dest: id
+      type: text
+    - dest: user_id
+      type: int
+      jsonpath: $.user.id
+    - dest: time
+      type: timestamp
+      jsonpath: $['meta']['time']
+  meta:
+    datadatecolumn: time
+    schema: analytics
+other:
+  dest: other
+  columns:
+    - dest: id
+      type: text
+  meta:
+    datadatecolumn: id
+    schema: analytics
+`
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := ParseConfig([]byte(sampleConfig), "events")
+	assert.NoError(t, err)
+	assert.Equal(t, &Config{
+		Name: "events",
+		Columns: []ColumnConfig{
+			{Name: "id", Type: "text"},
+			{Name: "user_id", Type: "int", JSONPath: "$.user.id"},
+			{Name: "time", Type: "timestamp", JSONPath: "$['meta']['time']"},
+		},
+		Meta: ConfigMeta{DataDateColumn: "time", Schema: "analytics"},
+	}, cfg)
+
+	_, err = ParseConfig([]byte(sampleConfig), "missing")
+	assert.Error(t, err)
+	_, err = ParseConfig([]byte(sampleConfig), "")
+	assert.Error(t, err)
+	_, err = ParseConfig([]byte("not: [yaml"), "events")
+	assert.Error(t, err)
+
+	cfg, err = ParseConfig([]byte("t:\n  dest: only\n"), "")
+	assert.NoError(t, err)
+	assert.Equal(t, "only", cfg.Name)
+}
+
+func TestGenerateJSONPaths(t *testing.T) {
+	cfg, err := ParseConfig([]byte(sampleConfig), "events")
+	assert.NoError(t, err)
+	data, err := GenerateJSONPaths(*cfg)
+	assert.NoError(t, err)
+	// in column order, with the column name as the default
+	assert.Equal(t, `{"jsonpaths":["$['id']","$.user.id","$['meta']['time']"]}`, string(data))
+
+	_, err = GenerateJSONPaths(Config{Name: "empty"})
+	assert.Error(t, err)
+	_, err = GenerateJSONPaths(Config{Name: "t", Columns: []ColumnConfig{{Name: "id", JSONPath: "user.id"}}})
+	assert.Error(t, err)
+}
+
+func TestLoadConfig(t *testing.T) {
+	cfg, err := LoadConfig(strings.NewReader(`
+events:
+  dest: events
+  columns:
+    - dest: id
+      type: text
+      notnull: true
+    - dest: count
+      type: int
+      defaultval: "0"
+  meta:
+    datadatecolumn: time
+    schema: analytics
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, []ColumnConfig{
+		{Name: "id", Type: "text", NotNull: true},
+		{Name: "count", Type: "int", DefaultVal: "0"},
+	}, cfg.Columns)
+	assert.Equal(t, "character varying(256)", cfg.Columns[0].RedshiftType())
+	assert.Equal(t, "integer", cfg.Columns[1].RedshiftType())
+	assert.Equal(t, "numeric(10,2)", ColumnConfig{Type: "numeric(10,2)"}.RedshiftType())
+
+	// several tables is ambiguous
+	_, err = LoadConfig(strings.NewReader(sampleConfig))
+	assert.Error(t, err)
+}
+
+func TestValidateColumns(t *testing.T) {
+	cfg := &Config{Name: "events", Columns: []ColumnConfig{
+		{Name: "id", Type: "text", NotNull: true},
+		{Name: "count", Type: "int", DefaultVal: "0"},
+		{Name: "added", Type: "bigint"},
+	}}
+
+	assert.NoError(t, cfg.ValidateColumns([]ColumnDef{
+		// different varchar lengths are fine
+		{Name: "id", Type: "character varying(512)", NotNull: true},
+		{Name: "count", Type: "integer", DefaultVal: "0"},
+		{Name: "added", Type: "bigint"},
+	}))
+
+	err := cfg.ValidateColumns([]ColumnDef{
+		{Name: "id", Type: "character varying(256)"},
+		{Name: "count", Type: "bigint", DefaultVal: "1"},
+		{Name: "removed", Type: "boolean"},
+	})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "+ added bigint: in the config but not the table")
+	assert.Contains(t, err.Error(), "- removed boolean: in the table but not the config")
+	assert.Contains(t, err.Error(), "~ count: type is integer in the config, bigint in the table")
+	assert.Contains(t, err.Error(), `~ count: default is "0" in the config, "1" in the table`)
+	assert.Contains(t, err.Error(), "~ id: notnull is true in the config, false in the table")
+	assert.NotContains(t, err.Error(), "~ id: type")
+}
+
+func TestCreateTableSQL(t *testing.T) {
+	cfg, err := LoadConfig(strings.NewReader(`
+events:
+  dest: events
+  columns:
+    - dest: id
+      type: text
+      notnull: true
+      primarykey: true
+      distkey: true
+    - dest: count
+      type: int
+      defaultval: "0"
+    - dest: time
+      type: timestamp
+      sortord: 1
+    - dest: user
+      type: bigint
+      sortord: 2
+  meta:
+    datadatecolumn: time
+    schema: analytics
+`))
+	assert.NoError(t, err)
+	sql, err := cfg.CreateTableSQL("analytics", "events")
+	assert.NoError(t, err)
+	assert.Equal(t, `CREATE TABLE IF NOT EXISTS "analytics"."events" (`+
+		`"id" character varying(256) NOT NULL PRIMARY KEY, "count" integer DEFAULT 0, `+
+		`"time" timestamp without time zone, "user" bigint) DISTKEY("id") SORTKEY("time", "user")`, sql)
+
+	// no keys, no key clauses
+	sql, err = (&Config{Columns: []ColumnConfig{{Name: "id", Type: "text"}}}).CreateTableSQL("s", "t")
+	assert.NoError(t, err)
+	assert.Equal(t, `CREATE TABLE IF NOT EXISTS "s"."t" ("id" character varying(256))`, sql)
+
+	// embedded quotes are doubled everywhere a name appears
+	sql, err = (&Config{Columns: []ColumnConfig{
+		{Name: `a"b`, Type: "int", DistKey: true, SortOrdinal: 1},
+	}}).CreateTableSQL(`my"schema`, "table")
+	assert.NoError(t, err)
+	assert.Equal(t, `CREATE TABLE IF NOT EXISTS "my""schema"."table" ("a""b" integer) DISTKEY("a""b") SORTKEY("a""b")`, sql)
+
+	for _, bad := range []*Config{
+		{},
+		{Columns: []ColumnConfig{{Name: "id"}}},
+		{Columns: []ColumnConfig{{Name: "a", Type: "int", DistKey: true}, {Name: "b", Type: "int", DistKey: true}}},
+		{Columns: []ColumnConfig{{Name: "a", Type: "int", SortOrdinal: 1}, {Name: "b", Type: "int", SortOrdinal: 1}}},
+		{Columns: []ColumnConfig{{Name: "a", Type: "int", SortOrdinal: 2}}},
+	} {
+		_, err := bad.CreateTableSQL("s", "t")
+		assert.Error(t, err)
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "events", "", expectedDate, "json")
+	reader := mapReaderProvider{f.ConfFile: sampleConfig}
+	data, err := f.ReadConfig(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, sampleConfig, string(data))
+	cfg, err := ParseConfig(data, "events")
+	assert.NoError(t, err)
+	assert.Equal(t, "events", cfg.Name)
+
+	_, err = f.ReadConfig(mapReaderProvider{})
+	assert.True(t, errors.Is(err, ErrConfigNotFound))
+	assert.Contains(t, err.Error(), f.ConfFile)
+
+	_, err = f.ReadConfig(mockReaderProvider{err: errors.New("denied")})
+	assert.EqualError(t, err, "error opening config file "+f.ConfFile+": denied")
+	assert.False(t, errors.Is(err, ErrConfigNotFound))
+}
+
+func TestWriteConfig(t *testing.T) {
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "events", "", expectedDate, "json")
+	cfg, err := ParseConfig([]byte(sampleConfig), "events")
+	assert.NoError(t, err)
+
+	w := &MockWriter{}
+	assert.NoError(t, f.WriteConfig(w, cfg))
+	written, err := LoadConfig(bytes.NewReader(w.Files[f.ConfFile]))
+	assert.NoError(t, err)
+	assert.Equal(t, cfg, written)
+
+	// it's there now
+	err = f.WriteConfig(w, cfg)
+	assert.EqualError(t, err, "config file "+f.ConfFile+" already exists")
+	cfg.Meta.Schema = "other"
+	assert.NoError(t, f.WriteConfigOverwrite(w, cfg))
+	written, err = LoadConfig(bytes.NewReader(w.Files[f.ConfFile]))
+	assert.NoError(t, err)
+	assert.Equal(t, "other", written.Meta.Schema)
+
+	assert.Error(t, f.WriteConfig(&MockWriter{}, &Config{}))
+	// a Writer that can't look for the file can only overwrite it
+	assert.Error(t, f.WriteConfig(struct{ Writer }{&MockWriter{}}, cfg))
+	err = f.WriteConfigOverwrite(&MockWriter{Err: errors.New("denied")}, cfg)
+	assert.EqualError(t, err, "error writing config file "+f.ConfFile+": denied")
+}
@@ -0,0 +1,315 @@
+package s3filepath
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Compression is how the data in an S3File is compressed
+type Compression int
+
+const (
+	// CompressionAuto means the compression should be inferred from the file's suffix
+	CompressionAuto Compression = iota
+	// CompressionNone is used for uncompressed files
+	CompressionNone
+	// CompressionGzip is used for gzipped files
+	CompressionGzip
+	// CompressionBzip2 is used for bzip2ed files
+	CompressionBzip2
+	// CompressionZstd is used for zstd compressed files
+	CompressionZstd
+)
+
+// String returns the COPY parameter for the compression, if there is one,
+// or Compression(n) if it isn't a known compression
+func (c Compression) String() string {
+	switch c {
+	case CompressionAuto, CompressionNone:
+		return ""
+	case CompressionGzip:
+		return "GZIP"
+	case CompressionBzip2:
+		return "BZIP2"
+	case CompressionZstd:
+		return "ZSTD"
+	default:
+		return fmt.Sprintf("Compression(%d)", int(c))
+	}
+}
+
+var compressionMagic = []struct {
+	magic       []byte
+	compression Compression
+}{
+	{[]byte{0x1f, 0x8b}, CompressionGzip},
+	{[]byte("BZh"), CompressionBzip2},
+	{[]byte{0x28, 0xb5, 0x2f, 0xfd}, CompressionZstd},
+}
+
+// DetectCompression sniffs the magic bytes at the start of the data to tell how it's compressed,
+// for files whose suffix doesn't say (e.g. gzipped files without .gz). Only the first few bytes
+// are read. Data that doesn't start with a known magic number is CompressionNone.
+func DetectCompression(reader io.Reader) (Compression, error) {
+	header := make([]byte, 4)
+	n, err := io.ReadFull(reader, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return CompressionAuto, fmt.Errorf("could not read data to detect compression: %s", err)
+	}
+	for _, m := range compressionMagic {
+		if bytes.HasPrefix(header[:n], m.magic) {
+			return m.compression, nil
+		}
+	}
+	return CompressionNone, nil
+}
+
+// Compression returns how the data file is compressed based on its suffix
+func (f *S3File) Compression() Compression {
+	suffix := strings.ToLower(normalizeSuffix(f.Suffix))
+	switch {
+	case suffix == "gz" || strings.HasSuffix(suffix, ".gz"):
+		return CompressionGzip
+	case suffix == "bz2" || strings.HasSuffix(suffix, ".bz2"):
+		return CompressionBzip2
+	case suffix == "zst" || strings.HasSuffix(suffix, ".zst"):
+		return CompressionZstd
+	default:
+		return CompressionNone
+	}
+}
+
+// CopyOptions control the COPY command generated for an S3File.
+// The zero value infers everything from the file's suffix.
+type CopyOptions struct {
+	// Format overrides the format inferred from the suffix. Manifest files obscure
+	// the underlying file types, so it must be set when copying from a manifest.
+	Format FileFormat
+	// Compression overrides the compression inferred from the suffix
+	Compression Compression
+	// JSONPaths is the jsonpaths file to use for JSON data. Defaults to 'auto'.
+	JSONPaths string
+	// Delimiter is the field delimiter for CSV data. Defaults to Redshift's, a comma.
+	Delimiter string
+	// Quote is the quote character for CSV data. Defaults to Redshift's, a double quote.
+	Quote string
+	// IgnoreHeaderRows is how many header rows at the top of CSV data to skip
+	IgnoreHeaderRows int
+	// MaxError is the number of bad rows COPY will skip before failing
+	MaxError int
+	// TimeFormat is how timestamps are written in CSV or JSON data: TimeFormatAuto, one of the
+	// epoch formats or an explicit format like "YYYY/MM/DD HH:MI:SS". Defaults to Redshift's.
+	TimeFormat string
+	// DateFormat is how dates are written in CSV or JSON data: TimeFormatAuto or an explicit
+	// format like "YYYY/MM/DD". Defaults to Redshift's.
+	DateFormat string
+	// NullAs is the string that loads as NULL in CSV or JSON data, e.g. \N. Backslashes are escaped.
+	NullAs string
+	// Region overrides the bucket's Region in the REGION clause, for when the bucket is really
+	// in a different region than the S3Bucket says, e.g. one shared across regions
+	Region string
+	// Columns are the table columns the file's fields are loaded into, in the file's order, for
+	// tables with more columns than the file. Defaults to every column in the table's order.
+	// Config.ColumnNames gives the columns of a config file.
+	Columns []string
+}
+
+// The special TimeFormat and DateFormat values
+const (
+	// TimeFormatAuto lets Redshift recognize the format of each value
+	TimeFormatAuto = "auto"
+	// TimeFormatEpochSecs reads timestamps as seconds since the epoch. It's only valid as a TimeFormat.
+	TimeFormatEpochSecs = "epochsecs"
+	// TimeFormatEpochMillisecs reads timestamps as milliseconds since the epoch. It's only valid as a TimeFormat.
+	TimeFormatEpochMillisecs = "epochmillisecs"
+)
+
+// CopyCommand returns the Redshift COPY command that loads the S3File into tableName.
+// tableName may be schema qualified; if it's empty the file's schema and table are used.
+// The IAM role and region come from the file's bucket, unless the region is overridden in opts.
+func (f *S3File) CopyCommand(tableName string, opts CopyOptions) (string, error) {
+	if f.Bucket.RedshiftRoleARN == "" {
+		return "", fmt.Errorf("bucket %s has no redshift role ARN to COPY with", f.Bucket.Name)
+	}
+	if tableName == "" {
+		tableName = f.Schema + "." + f.Table
+	}
+
+	format := opts.Format
+	if format == FormatUnknown {
+		format = f.Format()
+	}
+	compression := opts.Compression
+	if compression == CompressionAuto {
+		compression = f.Compression()
+	}
+	if format == FormatUnknown {
+		return "", fmt.Errorf("can't tell the format of %s, set it in the COPY options", f.GetDataFilename())
+	}
+	if format < FormatUnknown || format > FormatParquet {
+		return "", fmt.Errorf("unknown format %d", int(format))
+	}
+	if compression < CompressionAuto || compression > CompressionZstd {
+		return "", fmt.Errorf("unknown compression %d", int(compression))
+	}
+	if format == FormatParquet && opts.Compression != CompressionAuto && opts.Compression != CompressionNone {
+		return "", fmt.Errorf("%s compression can't be used with PARQUET, which is compressed internally", opts.Compression)
+	}
+	if opts.Delimiter != "" && format != FormatCSV {
+		return "", fmt.Errorf("a delimiter can only be used with CSV, not %s", format)
+	}
+	if opts.Quote != "" && format != FormatCSV {
+		return "", fmt.Errorf("a quote character can only be used with CSV, not %s", format)
+	}
+	if opts.IgnoreHeaderRows < 0 {
+		return "", fmt.Errorf("can't ignore %d header rows", opts.IgnoreHeaderRows)
+	}
+	if opts.IgnoreHeaderRows > 0 && format != FormatCSV {
+		return "", fmt.Errorf("header rows can only be ignored with CSV, not %s", format)
+	}
+	if opts.JSONPaths != "" && format != FormatJSON {
+		return "", fmt.Errorf("jsonpaths can only be used with JSON, not %s", format)
+	}
+	if err := validateConversionOptions(opts, format); err != nil {
+		return "", err
+	}
+	for _, column := range opts.Columns {
+		if column == "" {
+			return "", fmt.Errorf("column names to COPY into must not be empty")
+		}
+	}
+	region := opts.Region
+	if region == "" {
+		region = f.Bucket.Region
+	}
+	if region != "" && !regionRegex.MatchString(region) {
+		return "", fmt.Errorf("%q is not an AWS region", region)
+	}
+
+	target := quoteTableName(tableName)
+	if len(opts.Columns) > 0 {
+		columns := make([]string, 0, len(opts.Columns))
+		for _, column := range opts.Columns {
+			columns = append(columns, quoteIdent(column))
+		}
+		target += " (" + strings.Join(columns, ", ") + ")"
+	}
+	sql := []string{
+		fmt.Sprintf("COPY %s FROM %s", target, quoteString(f.GetDataFilename())),
+		fmt.Sprintf("IAM_ROLE %s", quoteString(f.Bucket.RedshiftRoleARN)),
+	}
+	if region != "" {
+		sql = append(sql, fmt.Sprintf("REGION %s", quoteString(region)))
+	}
+	if isManifest(f.Suffix) {
+		sql = append(sql, "MANIFEST")
+	}
+
+	switch format {
+	case FormatJSON:
+		jsonPaths := opts.JSONPaths
+		if jsonPaths == "" {
+			jsonPaths = "auto"
+		}
+		sql = append(sql, fmt.Sprintf("FORMAT AS JSON %s", quoteString(jsonPaths)))
+	case FormatParquet:
+		// parquet files are compressed internally, so COPY doesn't take a compression parameter,
+		// even if the suffix looks compressed
+		sql = append(sql, "FORMAT AS PARQUET")
+		compression = CompressionNone
+	default:
+		sql = append(sql, "FORMAT AS CSV")
+		if opts.Quote != "" {
+			sql = append(sql, fmt.Sprintf("QUOTE AS %s", quoteString(opts.Quote)))
+		}
+		if opts.Delimiter != "" {
+			sql = append(sql, fmt.Sprintf("DELIMITER AS %s", quoteString(opts.Delimiter)))
+		}
+		if opts.IgnoreHeaderRows > 0 {
+			sql = append(sql, fmt.Sprintf("IGNOREHEADER %d", opts.IgnoreHeaderRows))
+		}
+	}
+
+	if opts.TimeFormat != "" {
+		sql = append(sql, fmt.Sprintf("TIMEFORMAT %s", quoteString(normalizeTimeFormat(opts.TimeFormat))))
+	}
+	if opts.DateFormat != "" {
+		sql = append(sql, fmt.Sprintf("DATEFORMAT %s", quoteString(normalizeTimeFormat(opts.DateFormat))))
+	}
+	if opts.NullAs != "" {
+		// Redshift reads backslashes in the NULL AS string as escapes
+		sql = append(sql, fmt.Sprintf("NULL AS %s", quoteString(strings.Replace(opts.NullAs, `\`, `\\`, -1))))
+	}
+	if compression != CompressionNone {
+		sql = append(sql, compression.String())
+	}
+	if opts.MaxError > 0 {
+		sql = append(sql, fmt.Sprintf("MAXERROR %d", opts.MaxError))
+	}
+	return strings.Join(sql, " "), nil
+}
+
+// validateConversionOptions checks the time, date and null options make sense together and for the format
+func validateConversionOptions(opts CopyOptions, format FileFormat) error {
+	if format == FormatParquet && (opts.TimeFormat != "" || opts.DateFormat != "" || opts.NullAs != "") {
+		return fmt.Errorf("time, date and null formats can't be used with PARQUET")
+	}
+	for _, f := range []string{opts.TimeFormat, opts.DateFormat} {
+		// a format that mentions auto alongside anything else is ambiguous, e.g. "auto YYYY-MM-DD"
+		if normalizeTimeFormat(f) != TimeFormatAuto && strings.Contains(strings.ToLower(f), TimeFormatAuto) {
+			return fmt.Errorf("format %q mixes %q with an explicit format, use one or the other", f, TimeFormatAuto)
+		}
+	}
+	switch normalizeTimeFormat(opts.DateFormat) {
+	case TimeFormatEpochSecs, TimeFormatEpochMillisecs:
+		return fmt.Errorf("date format %q is only valid as a time format", opts.DateFormat)
+	}
+	return nil
+}
+
+// normalizeTimeFormat lowercases the special time formats, which Redshift expects in lowercase
+func normalizeTimeFormat(f string) string {
+	switch lower := strings.ToLower(strings.TrimSpace(f)); lower {
+	case TimeFormatAuto, TimeFormatEpochSecs, TimeFormatEpochMillisecs:
+		return lower
+	}
+	return f
+}
+
+// CopyCommandDetectingCompression is CopyCommand, but when opts doesn't set the compression
+// it's detected from the start of the data file's content rather than from its suffix, so a
+// suffix that disagrees with the content doesn't break the COPY.
+// content should be the data file, read from the beginning. Manifests are left to the suffix,
+// since their content is the list of files rather than the data.
+func (f *S3File) CopyCommandDetectingCompression(tableName string, opts CopyOptions, content io.Reader) (string, error) {
+	if opts.Compression == CompressionAuto && !isManifest(f.Suffix) {
+		compression, err := DetectCompression(content)
+		if err != nil {
+			return "", err
+		}
+		opts.Compression = compression
+	}
+	return f.CopyCommand(tableName, opts)
+}
+
+// quoteTableName quotes each part of a possibly schema qualified table name
+func quoteTableName(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = quoteIdent(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// quoteIdent quotes a schema, table or column name for use in SQL, doubling any double quotes in it.
+// Every identifier in the SQL we generate goes through it, so no name can end the quoting early.
+func quoteIdent(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// quoteString quotes a string literal for use in SQL
+func quoteString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
@@ -0,0 +1,273 @@
+package s3filepath
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testCopyBucket = S3Bucket{Name: "b", Region: "us-west-2", RedshiftRoleARN: "arn:aws:iam::123456789012:role/redshift"}
+
+func TestCopyCommand(t *testing.T) {
+	prefix := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z"
+	auth := "IAM_ROLE 'arn:aws:iam::123456789012:role/redshift' REGION 'us-west-2'"
+	for _, test := range []struct {
+		suffix   string
+		opts     CopyOptions
+		expected string
+	}{
+		{"json.gz", CopyOptions{},
+			`COPY "s"."t" FROM '` + prefix + `.json.gz' ` + auth + ` FORMAT AS JSON 'auto' GZIP`},
+		{"json", CopyOptions{JSONPaths: "s3://b/jsonpaths.json", MaxError: 10},
+			`COPY "s"."t" FROM '` + prefix + `.json' ` + auth + ` FORMAT AS JSON 's3://b/jsonpaths.json' MAXERROR 10`},
+		{".gz", CopyOptions{Delimiter: "|"},
+			`COPY "s"."t" FROM '` + prefix + `.gz' ` + auth + ` FORMAT AS CSV DELIMITER AS '|' GZIP`},
+		{"", CopyOptions{},
+			`COPY "s"."t" FROM '` + prefix + `' ` + auth + ` FORMAT AS CSV`},
+		{"", CopyOptions{Delimiter: "|", Quote: "%", IgnoreHeaderRows: 1},
+			`COPY "s"."t" FROM '` + prefix + `' ` + auth + ` FORMAT AS CSV QUOTE AS '%' DELIMITER AS '|' IGNOREHEADER 1`},
+		{".gz", CopyOptions{Delimiter: "|", IgnoreHeaderRows: 2},
+			`COPY "s"."t" FROM '` + prefix + `.gz' ` + auth + ` FORMAT AS CSV DELIMITER AS '|' IGNOREHEADER 2 GZIP`},
+		{"", CopyOptions{Quote: "'"},
+			`COPY "s"."t" FROM '` + prefix + `' ` + auth + ` FORMAT AS CSV QUOTE AS ` + "''''"},
+		{"json", CopyOptions{TimeFormat: "AUTO", DateFormat: "auto", NullAs: `\N`},
+			`COPY "s"."t" FROM '` + prefix + `.json' ` + auth + ` FORMAT AS JSON 'auto' TIMEFORMAT 'auto' DATEFORMAT 'auto' NULL AS '\\N'`},
+		{".gz", CopyOptions{TimeFormat: "YYYY/MM/DD HH:MI:SS", DateFormat: "YYYY/MM/DD"},
+			`COPY "s"."t" FROM '` + prefix + `.gz' ` + auth + ` FORMAT AS CSV TIMEFORMAT 'YYYY/MM/DD HH:MI:SS' DATEFORMAT 'YYYY/MM/DD' GZIP`},
+		{"", CopyOptions{TimeFormat: TimeFormatEpochMillisecs, MaxError: 1},
+			`COPY "s"."t" FROM '` + prefix + `' ` + auth + ` FORMAT AS CSV TIMEFORMAT 'epochmillisecs' MAXERROR 1`},
+		{"", CopyOptions{Compression: CompressionBzip2},
+			`COPY "s"."t" FROM '` + prefix + `' ` + auth + ` FORMAT AS CSV BZIP2`},
+		{"json.bz2", CopyOptions{},
+			`COPY "s"."t" FROM '` + prefix + `.json.bz2' ` + auth + ` FORMAT AS JSON 'auto' BZIP2`},
+		{"json.zst", CopyOptions{},
+			`COPY "s"."t" FROM '` + prefix + `.json.zst' ` + auth + ` FORMAT AS JSON 'auto' ZSTD`},
+		{".bz2", CopyOptions{},
+			`COPY "s"."t" FROM '` + prefix + `.bz2' ` + auth + ` FORMAT AS CSV BZIP2`},
+		{".zst", CopyOptions{},
+			`COPY "s"."t" FROM '` + prefix + `.zst' ` + auth + ` FORMAT AS CSV ZSTD`},
+		{"parquet", CopyOptions{},
+			`COPY "s"."t" FROM '` + prefix + `.parquet' ` + auth + ` FORMAT AS PARQUET`},
+		{"manifest", CopyOptions{Format: FormatJSON, Compression: CompressionGzip},
+			`COPY "s"."t" FROM '` + prefix + `.manifest' ` + auth + ` MANIFEST FORMAT AS JSON 'auto' GZIP`},
+	} {
+		f := buildS3File(testCopyBucket, "s", "t", "", expectedDate, test.suffix)
+		sql, err := f.CopyCommand("", test.opts)
+		assert.NoError(t, err, "suffix: %q", test.suffix)
+		assert.Equal(t, test.expected, sql, "suffix: %q", test.suffix)
+	}
+}
+
+func TestCopyCommandTableName(t *testing.T) {
+	f := buildS3File(testCopyBucket, "s", "t", "", expectedDate, "json")
+	sql, err := f.CopyCommand("staging", CopyOptions{})
+	assert.NoError(t, err)
+	assert.Contains(t, sql, `COPY "staging" FROM`)
+
+	sql, err = f.CopyCommand("other.t", CopyOptions{})
+	assert.NoError(t, err)
+	assert.Contains(t, sql, `COPY "other"."t" FROM`)
+}
+
+func TestQuoteIdent(t *testing.T) {
+	assert.Equal(t, `"events"`, quoteIdent("events"))
+	// reserved words are fine once quoted
+	assert.Equal(t, `"user"`, quoteIdent("user"))
+	assert.Equal(t, `"select"`, quoteIdent("select"))
+	assert.Equal(t, `"a""b"`, quoteIdent(`a"b`))
+	assert.Equal(t, `"x"" FROM 's3://evil'; --"`, quoteIdent(`x" FROM 's3://evil'; --`))
+	assert.Equal(t, `""""`, quoteIdent(`"`))
+	assert.Equal(t, `"s"."a""b"`, quoteTableName(`s.a"b`))
+
+	f := buildS3File(testCopyBucket, "s", "t", "", expectedDate, "json")
+	sql, err := f.CopyCommand(`order.a"b`, CopyOptions{Columns: []string{"user", `c"d`}})
+	assert.NoError(t, err)
+	assert.Contains(t, sql, `COPY "order"."a""b" ("user", "c""d") FROM`)
+}
+
+func TestCopyCommandErrors(t *testing.T) {
+	// manifests need an explicit format
+	f := buildS3File(testCopyBucket, "s", "t", "", expectedDate, "manifest")
+	_, err := f.CopyCommand("", CopyOptions{})
+	assert.Error(t, err)
+
+	f = buildS3File(testCopyBucket, "s", "t", "", expectedDate, "json")
+	_, err = f.CopyCommand("", CopyOptions{Delimiter: "|"})
+	assert.Error(t, err)
+
+	_, err = f.CopyCommand("", CopyOptions{Quote: "%"})
+	assert.Error(t, err)
+	_, err = f.CopyCommand("", CopyOptions{IgnoreHeaderRows: 1})
+	assert.Error(t, err)
+
+	f = buildS3File(testCopyBucket, "s", "t", "", expectedDate, ".gz")
+	_, err = f.CopyCommand("", CopyOptions{JSONPaths: "auto"})
+	assert.Error(t, err)
+	_, err = f.CopyCommand("", CopyOptions{IgnoreHeaderRows: -1})
+	assert.Error(t, err)
+
+	_, err = f.CopyCommand("", CopyOptions{Compression: Compression(9)})
+	assert.EqualError(t, err, "unknown compression 9")
+	_, err = f.CopyCommand("", CopyOptions{Format: FileFormat(7), Delimiter: "|"})
+	assert.EqualError(t, err, "unknown format 7")
+
+	f = buildS3File(S3Bucket{Name: "b", Region: "us-west-2"}, "s", "t", "", expectedDate, "json")
+	_, err = f.CopyCommand("", CopyOptions{})
+	assert.Error(t, err)
+}
+
+func TestCopyCommandConversionErrors(t *testing.T) {
+	f := buildS3File(testCopyBucket, "s", "t", "", expectedDate, "json")
+	_, err := f.CopyCommand("", CopyOptions{TimeFormat: "auto YYYY-MM-DD HH:MI:SS"})
+	assert.EqualError(t, err, `format "auto YYYY-MM-DD HH:MI:SS" mixes "auto" with an explicit format, use one or the other`)
+	_, err = f.CopyCommand("", CopyOptions{DateFormat: "YYYY-MM-DD or auto"})
+	assert.Error(t, err)
+	_, err = f.CopyCommand("", CopyOptions{DateFormat: TimeFormatEpochSecs})
+	assert.EqualError(t, err, `date format "epochsecs" is only valid as a time format`)
+
+	f = buildS3File(testCopyBucket, "s", "t", "", expectedDate, "parquet")
+	for _, opts := range []CopyOptions{{TimeFormat: "auto"}, {DateFormat: "auto"}, {NullAs: `\N`}} {
+		_, err = f.CopyCommand("", opts)
+		assert.Error(t, err)
+	}
+
+	_, err = f.CopyCommand("", CopyOptions{Compression: CompressionGzip})
+	assert.EqualError(t, err, "GZIP compression can't be used with PARQUET, which is compressed internally")
+	_, err = f.CopyCommand("", CopyOptions{Compression: CompressionNone})
+	assert.NoError(t, err)
+}
+
+func TestCompression(t *testing.T) {
+	for suffix, compression := range map[string]Compression{
+		"json.gz":    CompressionGzip,
+		".gz":        CompressionGzip,
+		"json.bz2":   CompressionBzip2,
+		".bz2":       CompressionBzip2,
+		"json.zst":   CompressionZstd,
+		".zst":       CompressionZstd,
+		"parquet.gz": CompressionGzip,
+		"json":       CompressionNone,
+		"":           CompressionNone,
+		"manifest":   CompressionNone,
+	} {
+		f := S3File{Suffix: suffix}
+		assert.Equal(t, compression, f.Compression(), "suffix: %q", suffix)
+	}
+
+	assert.Equal(t, "BZIP2", CompressionBzip2.String())
+	assert.Equal(t, "", CompressionNone.String())
+	assert.Equal(t, "Compression(9)", Compression(9).String())
+}
+
+// errReader fails every read with err
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func TestDetectCompression(t *testing.T) {
+	for _, test := range []struct {
+		content     []byte
+		compression Compression
+	}{
+		{[]byte{0x1f, 0x8b, 0x08, 0x00, 0x00}, CompressionGzip},
+		{[]byte("BZh91AY&SY"), CompressionBzip2},
+		{[]byte{0x28, 0xb5, 0x2f, 0xfd, 0x04}, CompressionZstd},
+		{[]byte(`{"id": 1}`), CompressionNone},
+		{[]byte{0x1f}, CompressionNone},
+		{[]byte{}, CompressionNone},
+	} {
+		compression, err := DetectCompression(bytes.NewReader(test.content))
+		assert.NoError(t, err)
+		assert.Equal(t, test.compression, compression, "content: %v", test.content)
+	}
+
+	_, err := DetectCompression(errReader{errors.New("connection reset")})
+	assert.Error(t, err)
+
+	// only the first few bytes are read
+	r := bytes.NewReader(append([]byte{0x1f, 0x8b}, make([]byte, 1024)...))
+	_, err = DetectCompression(r)
+	assert.NoError(t, err)
+	assert.True(t, r.Len() >= 1020)
+}
+
+func TestCopyCommandDetectingCompression(t *testing.T) {
+	// gzipped, but without a .gz suffix
+	f := buildS3File(testCopyBucket, "s", "t", "", expectedDate, "json")
+	sql, err := f.CopyCommandDetectingCompression("", CopyOptions{}, bytes.NewReader([]byte{0x1f, 0x8b, 0x08}))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(sql, "FORMAT AS JSON 'auto' GZIP"), sql)
+
+	// a .gz suffix on uncompressed data
+	f = buildS3File(testCopyBucket, "s", "t", "", expectedDate, "json.gz")
+	sql, err = f.CopyCommandDetectingCompression("", CopyOptions{}, strings.NewReader(`{"id": 1}`))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(sql, "FORMAT AS JSON 'auto'"), sql)
+
+	f = buildS3File(testCopyBucket, "s", "t", "", expectedDate, "")
+	sql, err = f.CopyCommandDetectingCompression("", CopyOptions{}, bytes.NewReader([]byte{0x28, 0xb5, 0x2f, 0xfd}))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(sql, "FORMAT AS CSV ZSTD"), sql)
+
+	// an explicit compression wins
+	sql, err = f.CopyCommandDetectingCompression("", CopyOptions{Compression: CompressionBzip2}, bytes.NewReader([]byte{0x1f, 0x8b}))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(sql, "FORMAT AS CSV BZIP2"), sql)
+}
+
+func TestCopyCommandRegion(t *testing.T) {
+	f := buildS3File(testCopyBucket, "s", "t", "", expectedDate, "json")
+	sql, err := f.CopyCommand("", CopyOptions{})
+	assert.NoError(t, err)
+	assert.Contains(t, sql, " REGION 'us-west-2' ")
+
+	sql, err = f.CopyCommand("", CopyOptions{Region: "eu-central-1"})
+	assert.NoError(t, err)
+	assert.Contains(t, sql, " REGION 'eu-central-1' ")
+	assert.NotContains(t, sql, "us-west-2")
+
+	// without a region COPY assumes the cluster's
+	noRegion := testCopyBucket
+	noRegion.Region = ""
+	sql, err = buildS3File(noRegion, "s", "t", "", expectedDate, "json").CopyCommand("", CopyOptions{})
+	assert.NoError(t, err)
+	assert.NotContains(t, sql, "REGION")
+
+	_, err = f.CopyCommand("", CopyOptions{Region: "us-west-2'; DROP TABLE s.t; --"})
+	assert.EqualError(t, err, `"us-west-2'; DROP TABLE s.t; --" is not an AWS region`)
+	noRegion.Region = "Oregon"
+	_, err = buildS3File(noRegion, "s", "t", "", expectedDate, "json").CopyCommand("", CopyOptions{})
+	assert.Error(t, err)
+}
+
+func TestCopyCommandColumns(t *testing.T) {
+	f := buildS3File(testCopyBucket, "s", "t", "", expectedDate, "json")
+	cfg, err := ParseConfig([]byte(`
+t:
+  dest: t
+  columns:
+    - dest: id
+      type: text
+    - dest: user
+      type: text
+    - dest: time
+      type: timestamp
+`), "t")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"id", "user", "time"}, cfg.ColumnNames())
+
+	// user and time are reserved words
+	sql, err := f.CopyCommand("", CopyOptions{Columns: cfg.ColumnNames()})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(sql, `COPY "s"."t" ("id", "user", "time") FROM '`+f.GetDataFilename()+`' `), sql)
+
+	_, err = f.CopyCommand("", CopyOptions{Columns: []string{"id", ""}})
+	assert.EqualError(t, err, "column names to COPY into must not be empty")
+}
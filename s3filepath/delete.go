@@ -0,0 +1,75 @@
+package s3filepath
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Deleter deletes files. Deleting a file that doesn't exist should return an error
+// that looks like S3's not found (a 404 or NoSuchKey), so callers can tell it was already gone.
+type Deleter interface {
+	Delete(path string) error
+}
+
+// S3Deleter deletes objects from S3
+type S3Deleter struct{}
+
+// deleteObjectAPI is the part of the s3 client needed for Delete
+type deleteObjectAPI interface {
+	headObjectAPI
+	DeleteObject(*s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error)
+}
+
+// Delete deletes the object. S3 doesn't say whether a deleted object existed,
+// so it's looked up first to return a not found error for missing objects.
+func (S3Deleter) Delete(path string) error {
+	bucket, _, err := splitS3Path(path)
+	if err != nil {
+		return err
+	}
+	client, err := s3ClientForBucket(bucket)
+	if err != nil {
+		return err
+	}
+	return deleteObject(client, path)
+}
+
+func deleteObject(client deleteObjectAPI, path string) error {
+	if _, err := headObject(client, path); err != nil {
+		return err
+	}
+	bucket, key, err := splitS3Path(path)
+	if err != nil {
+		return err
+	}
+	_, err = client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+// Delete deletes the S3File's data file, e.g. once it's been loaded so it's never loaded again.
+// A data file that's already gone isn't an error, it's just logged to the package Logger.
+func (f *S3File) Delete(d Deleter) error {
+	return deleteIfExists(d, f.GetDataFilename())
+}
+
+// DeleteWithConfig deletes the S3File's data file and its config file
+func (f *S3File) DeleteWithConfig(d Deleter) error {
+	if err := f.Delete(d); err != nil {
+		return err
+	}
+	return deleteIfExists(d, f.ConfFile)
+}
+
+func deleteIfExists(d Deleter, path string) error {
+	if err := d.Delete(path); err != nil {
+		if isNotFound(err) {
+			currentLogger().Log("s3file-already-deleted", map[string]interface{}{"path": path})
+			return nil
+		}
+		return err
+	}
+	return nil
+}
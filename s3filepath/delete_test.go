@@ -0,0 +1,80 @@
+package s3filepath
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+// MockDeleter deletes from a set of existing paths
+type MockDeleter struct {
+	ExistingPaths map[string]bool
+	Deleted       []string
+	Err           error
+}
+
+func (md *MockDeleter) Delete(path string) error {
+	if md.Err != nil {
+		return md.Err
+	}
+	if !md.ExistingPaths[path] {
+		return awserr.New(s3.ErrCodeNoSuchKey, "no such key", nil)
+	}
+	delete(md.ExistingPaths, path)
+	md.Deleted = append(md.Deleted, path)
+	return nil
+}
+
+func TestDelete(t *testing.T) {
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json")
+	d := &MockDeleter{ExistingPaths: map[string]bool{f.GetDataFilename(): true, f.ConfFile: true}}
+
+	assert.NoError(t, f.Delete(d))
+	assert.Equal(t, []string{f.GetDataFilename()}, d.Deleted)
+	assert.True(t, d.ExistingPaths[f.ConfFile])
+
+	// already gone is a no-op, logged to the package Logger
+	l := &recordingLogger{}
+	SetLogger(l)
+	defer SetLogger(nil)
+	assert.NoError(t, f.Delete(d))
+	assert.Len(t, d.Deleted, 1)
+	assert.Equal(t, []loggedEvent{
+		{"s3file-already-deleted", map[string]interface{}{"path": f.GetDataFilename()}},
+	}, l.events)
+
+	assert.NoError(t, f.DeleteWithConfig(d))
+	assert.Equal(t, []string{f.GetDataFilename(), f.ConfFile}, d.Deleted)
+	assert.Empty(t, d.ExistingPaths)
+
+	d.Err = errors.New("access denied")
+	assert.Error(t, f.Delete(d))
+	assert.Error(t, f.DeleteWithConfig(d))
+}
+
+type mockDeleteObject struct {
+	mockHeadObject
+	deleted *s3.DeleteObjectInput
+}
+
+func (m *mockDeleteObject) DeleteObject(input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	m.deleted = input
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func TestDeleteObject(t *testing.T) {
+	m := &mockDeleteObject{mockHeadObject: mockHeadObject{output: &s3.HeadObjectOutput{}}}
+	assert.NoError(t, deleteObject(m, "s3://b/s/t/file.json"))
+	assert.Equal(t, "b", aws.StringValue(m.deleted.Bucket))
+	assert.Equal(t, "s/t/file.json", aws.StringValue(m.deleted.Key))
+
+	// missing objects aren't deleted, and are reported as not found
+	m = &mockDeleteObject{mockHeadObject: mockHeadObject{err: awserr.NewRequestFailure(awserr.New("NotFound", "not found", nil), 404, "id")}}
+	err := deleteObject(m, "s3://b/s/t/file.json")
+	assert.True(t, isNotFound(err))
+	assert.Nil(t, m.deleted)
+}
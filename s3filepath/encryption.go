@@ -0,0 +1,59 @@
+package s3filepath
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// The server side encryption modes an S3Bucket can write objects with
+const (
+	SSEModeAES256 = s3.ServerSideEncryptionAes256
+	SSEModeKMS    = s3.ServerSideEncryptionAwsKms
+)
+
+// Encryption is the server side encryption objects are written with. The zero value leaves it
+// to the bucket's default encryption. Reads don't need it, S3 decrypts transparently.
+type Encryption struct {
+	// SSEMode is SSEModeAES256 or SSEModeKMS, or empty for the bucket's default
+	SSEMode string
+	// KMSKeyID is the KMS key to encrypt with when SSEMode is SSEModeKMS.
+	// If it's empty S3 uses the account's default KMS key.
+	KMSKeyID string
+}
+
+// Encryption returns the bucket's SSEMode and KMSKeyID
+func (b S3Bucket) Encryption() Encryption {
+	return Encryption{SSEMode: b.SSEMode, KMSKeyID: b.KMSKeyID}
+}
+
+// IsZero returns whether the bucket's default encryption is used
+func (e Encryption) IsZero() bool {
+	return e == Encryption{}
+}
+
+// Validate checks that SSEMode is known and that a KMSKeyID is only set for SSEModeKMS
+func (e Encryption) Validate() error {
+	switch e.SSEMode {
+	case "", SSEModeAES256, SSEModeKMS:
+	default:
+		return fmt.Errorf("SSEMode %q must be %q or %q", e.SSEMode, SSEModeAES256, SSEModeKMS)
+	}
+	if e.KMSKeyID != "" && e.SSEMode != SSEModeKMS {
+		return fmt.Errorf("KMSKeyID can only be used with SSEMode %q", SSEModeKMS)
+	}
+	return nil
+}
+
+// params returns the ServerSideEncryption and SSEKMSKeyId to put in a request, nil if not set
+func (e Encryption) params() (*string, *string) {
+	var sse, kmsKeyID *string
+	if e.SSEMode != "" {
+		sse = aws.String(e.SSEMode)
+	}
+	if e.KMSKeyID != "" {
+		kmsKeyID = aws.String(e.KMSKeyID)
+	}
+	return sse, kmsKeyID
+}
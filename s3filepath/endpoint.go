@@ -0,0 +1,49 @@
+package s3filepath
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Endpoint is a custom S3-compatible endpoint to check for files against,
+// e.g. MinIO on-prem or LocalStack in tests. Paths are still given as s3://bucket/key.
+//
+// To test against LocalStack, start it with the s3 service (it listens on
+// http://localhost:4566 by default), create the bucket and upload the files, then use
+//
+//	pc, err := NewEndpointPathChecker(Endpoint{URL: "http://localhost:4566", PathStyle: true})
+//
+// as the PathChecker for CreateS3File. Credentials come from the usual AWS environment
+// variables; LocalStack accepts any, e.g. AWS_ACCESS_KEY_ID=test AWS_SECRET_ACCESS_KEY=test.
+type Endpoint struct {
+	// URL is the endpoint's base url, e.g. http://localhost:9000
+	URL string
+	// Region defaults to us-east-1, which MinIO and LocalStack both use unless told otherwise
+	Region string
+	// PathStyle puts the bucket in the path (http://host/bucket/key) rather than the host name
+	// (http://bucket.host/key). MinIO and LocalStack usually need it.
+	PathStyle bool
+}
+
+func (e Endpoint) awsConfig() *aws.Config {
+	region := e.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	return aws.NewConfig().WithEndpoint(e.URL).WithRegion(region).WithS3ForcePathStyle(e.PathStyle)
+}
+
+// NewEndpointPathChecker returns an SDKPathChecker whose client talks to the given endpoint
+func NewEndpointPathChecker(e Endpoint) (SDKPathChecker, error) {
+	if e.URL == "" {
+		return SDKPathChecker{}, fmt.Errorf("endpoint URL must not be empty")
+	}
+	sess, err := session.NewSession(e.awsConfig())
+	if err != nil {
+		return SDKPathChecker{}, fmt.Errorf("failed to create session for endpoint %s: %s", e.URL, err)
+	}
+	return NewSDKPathChecker(s3.New(sess)), nil
+}
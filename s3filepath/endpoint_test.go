@@ -0,0 +1,29 @@
+package s3filepath
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpointConfig(t *testing.T) {
+	cfg := Endpoint{URL: "http://localhost:4566", PathStyle: true}.awsConfig()
+	assert.Equal(t, "http://localhost:4566", aws.StringValue(cfg.Endpoint))
+	assert.Equal(t, "us-east-1", aws.StringValue(cfg.Region))
+	assert.True(t, aws.BoolValue(cfg.S3ForcePathStyle))
+
+	cfg = Endpoint{URL: "https://minio.internal:9000", Region: "eu-west-1"}.awsConfig()
+	assert.Equal(t, "https://minio.internal:9000", aws.StringValue(cfg.Endpoint))
+	assert.Equal(t, "eu-west-1", aws.StringValue(cfg.Region))
+	assert.False(t, aws.BoolValue(cfg.S3ForcePathStyle))
+}
+
+func TestNewEndpointPathChecker(t *testing.T) {
+	_, err := NewEndpointPathChecker(Endpoint{})
+	assert.Error(t, err)
+
+	pc, err := NewEndpointPathChecker(Endpoint{URL: "http://localhost:4566", PathStyle: true})
+	assert.NoError(t, err)
+	assert.NotNil(t, pc.client)
+}
@@ -0,0 +1,106 @@
+package s3filepath
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/Clever/pathio"
+)
+
+// ReaderProvider opens files for reading
+type ReaderProvider interface {
+	Reader(path string) (io.ReadCloser, error)
+}
+
+// DefaultReadBufferSize is how much of a file EstimateRows and VerifyChecksum hold in memory at
+// once unless WithReadBufferSize says otherwise. They stream files through a buffer this size,
+// so memory use doesn't grow with the size of the file.
+const DefaultReadBufferSize = 64 * 1024
+
+// readBuffer returns a buffer of the S3File's read buffer size
+func (f *S3File) readBuffer() []byte {
+	size := f.readBufferSize
+	if size <= 0 {
+		size = DefaultReadBufferSize
+	}
+	return make([]byte, size)
+}
+
+// onlyReader hides any WriterTo the reader has, so io.CopyBuffer always uses the buffer it's given
+type onlyReader struct {
+	io.Reader
+}
+
+// S3ReaderProvider reads files from S3 with pathio
+type S3ReaderProvider struct{}
+
+// Reader opens the file with pathio.Reader
+func (S3ReaderProvider) Reader(path string) (io.ReadCloser, error) {
+	return pathio.Reader(path)
+}
+
+// EstimateRows estimates how many rows the data file has by counting the lines in its first
+// sampleBytes and scaling that up to the size of the whole object. If the sample covers the
+// whole file the count is exact (a last line without a newline still counts).
+//
+// This is a heuristic: it's only as good as the sample is typical of the rest of the file,
+// and it assumes one row per line, so it is wildly off for compressed, parquet and manifest files.
+//
+// The sample is streamed through the S3File's read buffer, see DefaultReadBufferSize, so it
+// can be as big as the whole file. The object size comes from Object, so the file needs to have been found by a Statter.
+// If it wasn't and the reader is also a Statter, it's used to look the size up.
+func (f *S3File) EstimateRows(reader ReaderProvider, sampleBytes int) (int64, error) {
+	if sampleBytes <= 0 {
+		return 0, fmt.Errorf("sample size must be positive, got %d", sampleBytes)
+	}
+	dataPath := f.GetDataFilename()
+	info := f.Object
+	if info == (S3ObjectInfo{}) {
+		statter, ok := reader.(Statter)
+		if !ok {
+			return 0, fmt.Errorf("size of %s is unknown, it wasn't found by a Statter", dataPath)
+		}
+		var err error
+		if info, err = statter.Stat(dataPath); err != nil {
+			return 0, fmt.Errorf("error looking up the size of %s: %w", dataPath, err)
+		}
+	}
+
+	r, err := reader.Reader(dataPath)
+	if err != nil {
+		return 0, fmt.Errorf("error opening %s: %w", dataPath, err)
+	}
+	defer r.Close()
+
+	// count the lines a buffer at a time, so big samples don't have to fit in memory
+	sample := io.LimitReader(r, int64(sampleBytes))
+	buf := f.readBuffer()
+	var lines, sampled int64
+	var last byte
+	for {
+		n, err := sample.Read(buf)
+		if n > 0 {
+			lines += int64(bytes.Count(buf[:n], []byte("\n")))
+			sampled += int64(n)
+			last = buf[n-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("error reading %s: %w", dataPath, err)
+		}
+	}
+
+	if sampled >= info.Size {
+		if sampled > 0 && last != '\n' {
+			lines++
+		}
+		return lines, nil
+	}
+	if lines == 0 {
+		return 0, fmt.Errorf("no lines in the first %d bytes of %s to estimate from", sampled, dataPath)
+	}
+	return lines * info.Size / sampled, nil
+}
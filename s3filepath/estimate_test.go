@@ -0,0 +1,152 @@
+package s3filepath
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockReaderProvider serves fixed content for every path
+type mockReaderProvider struct {
+	content string
+	err     error
+}
+
+func (m mockReaderProvider) Reader(path string) (io.ReadCloser, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return ioutil.NopCloser(strings.NewReader(m.content)), nil
+}
+
+// statReaderProvider is also a Statter, reporting the content's size
+type statReaderProvider struct {
+	mockReaderProvider
+}
+
+func (s statReaderProvider) Stat(path string) (S3ObjectInfo, error) {
+	return S3ObjectInfo{Size: int64(len(s.content)), ETag: "etag"}, nil
+}
+
+func TestEstimateRows(t *testing.T) {
+	// 10 rows of 10 bytes each
+	content := strings.Repeat("123456789\n", 10)
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json")
+
+	// a sample of 3 rows out of 1000 bytes
+	f.Object = S3ObjectInfo{Size: 1000}
+	rows, err := f.EstimateRows(mockReaderProvider{content: content}, 30)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), rows)
+
+	// the whole file is exact, including a last line without a newline
+	f.Object = S3ObjectInfo{Size: int64(len(content) + 3)}
+	rows, err = f.EstimateRows(mockReaderProvider{content: content + "abc"}, 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), rows)
+
+	f.Object = S3ObjectInfo{}
+	_, err = f.EstimateRows(mockReaderProvider{content: content}, 30)
+	assert.Error(t, err)
+	rows, err = f.EstimateRows(statReaderProvider{mockReaderProvider{content: content}}, 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), rows)
+
+	f.Object = S3ObjectInfo{Size: 1000}
+	_, err = f.EstimateRows(mockReaderProvider{content: content}, 5)
+	assert.Error(t, err, "no newline in the sample")
+	_, err = f.EstimateRows(mockReaderProvider{content: content}, 0)
+	assert.Error(t, err)
+	_, err = f.EstimateRows(mockReaderProvider{err: errors.New("denied")}, 30)
+	assert.EqualError(t, err, "error opening "+f.GetDataFilename()+": denied")
+}
+
+// syntheticReader generates size bytes of 10 byte lines without holding them in memory
+type syntheticReader struct {
+	remaining int64
+}
+
+func (s *syntheticReader) Read(p []byte) (int, error) {
+	if s.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > s.remaining {
+		p = p[:s.remaining]
+	}
+	for i := range p {
+		if (s.remaining-int64(i))%10 == 1 {
+			p[i] = '\n'
+		} else {
+			p[i] = 'x'
+		}
+	}
+	s.remaining -= int64(len(p))
+	return len(p), nil
+}
+
+func (s *syntheticReader) Close() error { return nil }
+
+// syntheticReaderProvider serves a new syntheticReader of size bytes for every path
+type syntheticReaderProvider struct {
+	size int64
+}
+
+func (s syntheticReaderProvider) Reader(path string) (io.ReadCloser, error) {
+	return &syntheticReader{remaining: s.size}, nil
+}
+
+// allocated returns how many bytes were allocated while running fn
+func allocated(fn func()) uint64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	fn()
+	runtime.ReadMemStats(&after)
+	return after.TotalAlloc - before.TotalAlloc
+}
+
+func TestStreamingReadsUseBoundedMemory(t *testing.T) {
+	const size = 64000000
+	reader := syntheticReaderProvider{size: size}
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json")
+	f.Object.Size = size
+
+	// sampling the whole file counts it exactly
+	var rows int64
+	var err error
+	used := allocated(func() { rows, err = f.EstimateRows(reader, size) })
+	assert.NoError(t, err)
+	assert.Equal(t, int64(size/10), rows)
+	assert.True(t, used < 1<<20, "allocated %d bytes", used)
+
+	used = allocated(func() { _, err = f.VerifyChecksum(reader, "", ChecksumSHA256) })
+	assert.NoError(t, err)
+	assert.True(t, used < 1<<20, "allocated %d bytes", used)
+
+	// the buffer size is configurable, and still gives the same answer
+	small, err := NewS3File(f.Bucket, "s", "t", WithDate(expectedDate), WithSuffix("json"), WithReadBufferSize(7))
+	assert.NoError(t, err)
+	small.Object.Size = 1000
+	rows, err = small.EstimateRows(syntheticReaderProvider{size: 1000}, 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), rows)
+	assert.Len(t, small.readBuffer(), 7)
+	assert.Len(t, f.readBuffer(), DefaultReadBufferSize)
+}
+
+func BenchmarkVerifyChecksum(b *testing.B) {
+	reader := syntheticReaderProvider{size: 16 << 20}
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json")
+	b.ReportAllocs()
+	b.SetBytes(reader.size)
+	for i := 0; i < b.N; i++ {
+		if _, err := f.VerifyChecksum(reader, "", ChecksumMD5); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package s3filepath
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// GCSObjectChecker is the part of a GCS client GCSPathChecker needs. The GCS client library
+// isn't a dependency of this repo, so wrap your *storage.Client with something like:
+//
+//	type gcsClient struct{ *storage.Client }
+//
+//	func (c gcsClient) ObjectExists(ctx context.Context, bucket, object string) (bool, error) {
+//		_, err := c.Bucket(bucket).Object(object).Attrs(ctx)
+//		if err == storage.ErrObjectNotExist {
+//			return false, nil
+//		}
+//		return err == nil, err
+//	}
+type GCSObjectChecker interface {
+	ObjectExists(ctx context.Context, bucket, object string) (bool, error)
+}
+
+// GCSPathChecker checks for files in GCS, for buckets with Scheme set to SchemeGCS
+type GCSPathChecker struct {
+	client GCSObjectChecker
+}
+
+// NewGCSPathChecker returns a GCSPathChecker using the given client
+func NewGCSPathChecker(client GCSObjectChecker) GCSPathChecker {
+	return GCSPathChecker{client: client}
+}
+
+// FileExists returns whether the file exists. Errors are treated as the file not existing,
+// use FileExistsErr to see them.
+func (c GCSPathChecker) FileExists(path string) bool {
+	return existsOrFalse(c.FileExistsErr(path))
+}
+
+// FileExistsErr returns whether the file exists, or the error from GCS
+func (c GCSPathChecker) FileExistsErr(path string) (bool, error) {
+	return c.fileExists(context.Background(), path)
+}
+
+// FileExistsContext is FileExists, passing the context on to the client
+func (c GCSPathChecker) FileExistsContext(ctx context.Context, path string) bool {
+	return existsOrFalse(c.fileExists(ctx, path))
+}
+
+func (c GCSPathChecker) fileExists(ctx context.Context, path string) (bool, error) {
+	bucket, object, err := splitGCSPath(path)
+	if err != nil {
+		return false, err
+	}
+	return c.client.ObjectExists(ctx, bucket, object)
+}
+
+// splitGCSPath splits gs://bucket/object into the bucket and the object
+func splitGCSPath(path string) (string, string, error) {
+	scheme, rest, ok := splitScheme(path)
+	if !ok || scheme != SchemeGCS {
+		return "", "", fmt.Errorf("not a gs path: %s", path)
+	}
+	parts := strings.SplitN(rest, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("gs path has no bucket and object: %s", path)
+	}
+	return parts[0], parts[1], nil
+}
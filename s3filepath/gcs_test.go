@@ -0,0 +1,65 @@
+package s3filepath
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockGCSObjectChecker struct {
+	objects map[string]bool
+	err     error
+}
+
+func (m mockGCSObjectChecker) ObjectExists(ctx context.Context, bucket, object string) (bool, error) {
+	return m.objects[bucket+"/"+object], m.err
+}
+
+func TestGCSPathChecker(t *testing.T) {
+	pc := NewGCSPathChecker(mockGCSObjectChecker{objects: map[string]bool{"b/s/t/file.json": true}})
+	exists, err := pc.FileExistsErr("gs://b/s/t/file.json")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.False(t, pc.FileExists("gs://b/s/t/other.json"))
+
+	_, err = pc.FileExistsErr("s3://b/s/t/file.json")
+	assert.EqualError(t, err, "not a gs path: s3://b/s/t/file.json")
+	_, err = pc.FileExistsErr("gs://b")
+	assert.Error(t, err)
+
+	pc = NewGCSPathChecker(mockGCSObjectChecker{err: errors.New("forbidden")})
+	_, err = pc.FileExistsErr("gs://b/s/t/file.json")
+	assert.EqualError(t, err, "forbidden")
+	assert.False(t, pc.FileExists("gs://b/s/t/file.json"))
+}
+
+func TestCreateS3FileGCS(t *testing.T) {
+	bucket := S3Bucket{Name: "b", Scheme: SchemeGCS}
+	dataPath := "gs://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z.json.gz"
+	pc := NewGCSPathChecker(mockGCSObjectChecker{objects: map[string]bool{
+		"b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z.json.gz": true,
+	}})
+
+	f, err := CreateS3File(pc, bucket, "s", "t", "", expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, dataPath, f.GetDataFilename())
+	assert.Equal(t, "gs://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/config_s_t_2015-11-10T23:00:00Z.yml",
+		f.ConfFile)
+
+	parsed, err := ParseDataFilename(dataPath)
+	assert.NoError(t, err)
+	assert.Equal(t, bucket, parsed.Bucket)
+	assert.Equal(t, dataPath, parsed.GetDataFilename())
+}
+
+func TestS3BucketValidateScheme(t *testing.T) {
+	bucket := S3Bucket{Name: "b", Region: "us-west-2", RedshiftRoleARN: "arn:aws:iam::123456789012:role/redshift"}
+	for _, scheme := range []string{"", SchemeS3, SchemeGCS} {
+		bucket.Scheme = scheme
+		assert.NoError(t, bucket.Validate())
+	}
+	bucket.Scheme = "https"
+	assert.Error(t, bucket.Validate())
+}
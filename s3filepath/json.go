@@ -0,0 +1,96 @@
+package s3filepath
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// s3FileJSON is the serialized form of an S3File
+type s3FileJSON struct {
+	Bucket     S3Bucket `json:"bucket"`
+	Schema     string   `json:"schema"`
+	Table      string   `json:"table"`
+	Suffix     string   `json:"suffix"`
+	DataDate   string   `json:"data_date"`
+	DateLayout string   `json:"date_layout,omitempty"`
+	Subfolder  string   `json:"subfolder"`
+	ConfFile   string   `json:"conf_file"`
+	// Granularity is kept so the file still round-trips when compared as a whole
+	Granularity         PartitionGranularity `json:"granularity,omitempty"`
+	PartitionKeys       *PartitionKeys       `json:"partition_keys,omitempty"`
+	Part                string               `json:"part,omitempty"`
+	Separator           string               `json:"separator,omitempty"`
+	WithoutSchemaPrefix bool                 `json:"without_schema_prefix,omitempty"`
+	ExplicitSubfolder   bool                 `json:"explicit_subfolder,omitempty"`
+	// DataFilename is only there for readability, it's ignored when unmarshalling
+	DataFilename string        `json:"data_filename"`
+	Object       *S3ObjectInfo `json:"object,omitempty"`
+}
+
+// MarshalJSON serializes an S3File with DataDate in RFC3339 (in UTC, with fractional seconds
+// if there are any), along with its data filename to make queued messages easier to read.
+func (f S3File) MarshalJSON() ([]byte, error) {
+	j := s3FileJSON{
+		Bucket:              f.Bucket,
+		Schema:              f.Schema,
+		Table:               f.Table,
+		Suffix:              f.Suffix,
+		DataDate:            f.DataDate.UTC().Format(time.RFC3339Nano),
+		DateLayout:          f.DateLayout,
+		Subfolder:           f.Subfolder,
+		ConfFile:            f.ConfFile,
+		Granularity:         f.granularity,
+		Part:                f.Part,
+		Separator:           f.separator,
+		WithoutSchemaPrefix: f.withoutSchemaPrefix,
+		ExplicitSubfolder:   f.explicitSubfolder,
+		DataFilename:        f.GetDataFilename(),
+	}
+	if f.partitionKeys != (PartitionKeys{}) {
+		keys := f.partitionKeys
+		j.PartitionKeys = &keys
+	}
+	if f.Object != (S3ObjectInfo{}) {
+		object := f.Object
+		j.Object = &object
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON is the inverse of MarshalJSON. Subfolder and ConfFile are recomputed
+// the same way CreateS3File does if they're missing.
+func (f *S3File) UnmarshalJSON(data []byte) error {
+	var j s3FileJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	date, err := time.Parse(time.RFC3339Nano, j.DataDate)
+	if err != nil {
+		return fmt.Errorf("could not parse data_date %q: %s", j.DataDate, err)
+	}
+	opts := []S3FileOption{WithDate(date), WithConfFile(j.ConfFile), WithSuffix(j.Suffix),
+		WithDateLayout(j.DateLayout), WithPartitionGranularity(j.Granularity), WithSeparator(j.Separator)}
+	if j.PartitionKeys != nil {
+		opts = append(opts, WithPartitionKeys(*j.PartitionKeys))
+	}
+	if j.WithoutSchemaPrefix {
+		opts = append(opts, WithoutSchemaPrefix())
+	}
+	if j.ExplicitSubfolder {
+		opts = append(opts, WithExplicitSubfolder(j.Subfolder))
+	}
+	parsed := newS3File(j.Bucket, j.Schema, j.Table, opts...)
+	if j.Subfolder != "" {
+		parsed.Subfolder = j.Subfolder
+		if j.ConfFile == "" {
+			parsed.ConfFile = parsed.GetConfigFilename()
+		}
+	}
+	if j.Object != nil {
+		parsed.Object = *j.Object
+	}
+	parsed.Part = j.Part
+	*f = *parsed
+	return nil
+}
@@ -0,0 +1,72 @@
+package s3filepath
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestS3FileJSONRoundTrip(t *testing.T) {
+	bucket := S3Bucket{Name: "b", Region: "us-west-2", RedshiftRoleARN: "arn:aws:iam::123456789012:role/redshift"}
+	f := buildS3File(bucket, "s", "t", "", expectedDate, "json.gz")
+	f.Object = S3ObjectInfo{Size: 10, LastModified: expectedDate, ETag: "abc"}
+
+	data, err := json.Marshal(f)
+	assert.NoError(t, err)
+	var raw map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &raw))
+	assert.Equal(t, "2015-11-10T23:00:00Z", raw["data_date"])
+	assert.Equal(t, f.GetDataFilename(), raw["data_filename"])
+
+	var parsed S3File
+	assert.NoError(t, json.Unmarshal(data, &parsed))
+	assert.Equal(t, *f, parsed)
+
+	// without an object, or with a supplied config file
+	f = buildS3File(bucket, "s", "t", "s3://b/conf.yml", expectedDate, "")
+	data, err = json.Marshal(f)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), `"object"`)
+	parsed = S3File{}
+	assert.NoError(t, json.Unmarshal(data, &parsed))
+	assert.Equal(t, *f, parsed)
+
+	f, err = NewS3File(bucket, "s", "t", WithDate(expectedDate), WithPartitionGranularity(PartitionHour))
+	assert.NoError(t, err)
+	data, err = json.Marshal(f)
+	assert.NoError(t, err)
+	parsed = S3File{}
+	assert.NoError(t, json.Unmarshal(data, &parsed))
+	assert.Equal(t, *f, parsed)
+
+	var b S3Bucket
+	data, err = json.Marshal(bucket)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(data, &b))
+	assert.Equal(t, bucket, b)
+}
+
+func TestS3FileUnmarshalJSONRecomputes(t *testing.T) {
+	var f S3File
+	assert.NoError(t, json.Unmarshal([]byte(`{"bucket":{"name":"b"},"schema":"s","table":"t","suffix":"json","data_date":"2015-11-10T15:00:00-08:00"}`), &f))
+	expected := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json")
+	assert.Equal(t, *expected, f)
+	assert.Equal(t, time.UTC, f.DataDate.Location())
+
+	assert.Error(t, json.Unmarshal([]byte(`{"schema":"s","table":"t","data_date":"yesterday"}`), &f))
+}
+
+func TestS3FileJSONPart(t *testing.T) {
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json.gz")
+	f.Part = "part-00001"
+	data, err := json.Marshal(f)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"part":"part-00001"`)
+
+	var parsed S3File
+	assert.NoError(t, json.Unmarshal(data, &parsed))
+	assert.Equal(t, *f, parsed)
+	assert.Equal(t, f.GetDataFilename(), parsed.GetDataFilename())
+}
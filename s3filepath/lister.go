@@ -0,0 +1,96 @@
+package s3filepath
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// S3Lister will use the AWS SDK to list what's under a prefix in S3, and will be used in prod.
+type S3Lister struct{}
+
+// listObjectsAPI is the part of the s3 client needed for List
+type listObjectsAPI interface {
+	ListObjectsV2Pages(*s3.ListObjectsV2Input, func(*s3.ListObjectsV2Output, bool) bool) error
+}
+
+// LimitLister is a Lister that can stop listing early. TablePrefixExists uses it when available.
+type LimitLister interface {
+	Lister
+	// ListLimit returns the full s3 paths of at most limit objects under the prefix
+	ListLimit(prefix string, limit int) ([]string, error)
+}
+
+// List returns the full s3 paths of every object under the prefix
+func (l S3Lister) List(prefix string) ([]string, error) {
+	return l.ListLimit(prefix, 0)
+}
+
+// ListLimit returns the full s3 paths of the first limit objects under the prefix, without
+// listing any more pages than that takes. A limit of 0 or less lists everything.
+func (S3Lister) ListLimit(prefix string, limit int) ([]string, error) {
+	bucket, _, err := splitS3Path(prefix)
+	if err != nil {
+		return nil, err
+	}
+	client, err := s3ClientForBucket(bucket)
+	if err != nil {
+		return nil, err
+	}
+	return listObjects(client, prefix, limit)
+}
+
+// maxListKeys is the most keys S3 returns in one page
+const maxListKeys = 1000
+
+func listObjects(client listObjectsAPI, prefix string, limit int) ([]string, error) {
+	bucket, key, err := splitS3Path(prefix)
+	if err != nil {
+		return nil, err
+	}
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(key),
+	}
+	if limit > 0 && limit < maxListKeys {
+		input.MaxKeys = aws.Int64(int64(limit))
+	}
+	var paths []string
+	err = client.ListObjectsV2Pages(input, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			if limit > 0 && len(paths) == limit {
+				return false
+			}
+			paths = append(paths, fmt.Sprintf("s3://%s/%s", bucket, aws.StringValue(obj.Key)))
+		}
+		return limit <= 0 || len(paths) < limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// TablePrefixExists returns whether there's anything at all under schema/table/ in the bucket.
+// When CreateS3File can't find a file this tells "no data for that date" apart from
+// "wrong bucket, schema or table", or a role that can't list the bucket (which is an error).
+// With a LimitLister, like S3Lister, it stops after the first key. Other Listers list everything
+// under the prefix, which can be slow for tables with lots of files.
+func TablePrefixExists(lister Lister, bucket S3Bucket, schema, table string) (bool, error) {
+	if err := validateNames(schema, table); err != nil {
+		return false, err
+	}
+	tablePrefix := s3Path(bucket, schema, table) + "/"
+	var paths []string
+	var err error
+	if ll, ok := lister.(LimitLister); ok {
+		paths, err = ll.ListLimit(tablePrefix, 1)
+	} else {
+		paths, err = lister.List(tablePrefix)
+	}
+	if err != nil {
+		return false, fmt.Errorf("error listing %s: %w", tablePrefix, err)
+	}
+	return len(paths) > 0, nil
+}
@@ -0,0 +1,109 @@
+package s3filepath
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockListObjects returns each page of keys in turn
+type mockListObjects struct {
+	input *s3.ListObjectsV2Input
+	pages [][]string
+}
+
+func (m *mockListObjects) ListObjectsV2Pages(input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool) error {
+	m.input = input
+	for i, keys := range m.pages {
+		page := &s3.ListObjectsV2Output{}
+		for _, key := range keys {
+			page.Contents = append(page.Contents, &s3.Object{Key: aws.String(key)})
+		}
+		if !fn(page, i == len(m.pages)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+func TestListObjects(t *testing.T) {
+	client := &mockListObjects{pages: [][]string{
+		{"s/t/a.json", "s/t/b.json"},
+		{"s/t/c.json"},
+	}}
+	paths, err := listObjects(client, "s3://b/s/t/", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "b", aws.StringValue(client.input.Bucket))
+	assert.Equal(t, "s/t/", aws.StringValue(client.input.Prefix))
+	assert.Nil(t, client.input.MaxKeys)
+	assert.Equal(t, []string{"s3://b/s/t/a.json", "s3://b/s/t/b.json", "s3://b/s/t/c.json"}, paths)
+
+	// a limit asks for fewer keys and stops paging once it has them
+	client.pages = append(client.pages, []string{"s/t/d.json"})
+	paths, err = listObjects(client, "s3://b/s/t/", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), aws.Int64Value(client.input.MaxKeys))
+	assert.Equal(t, []string{"s3://b/s/t/a.json"}, paths)
+	paths, err = listObjects(client, "s3://b/s/t/", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"s3://b/s/t/a.json", "s3://b/s/t/b.json", "s3://b/s/t/c.json"}, paths)
+
+	_, err = listObjects(client, "/s/t/", 0)
+	assert.Error(t, err)
+}
+
+func TestMockLister(t *testing.T) {
+	lister := MockLister{Paths: []string{"s3://b/s/t/a.json", "s3://b/s/u/a.json"}}
+	paths, err := lister.List("s3://b/s/t/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"s3://b/s/t/a.json"}, paths)
+}
+
+// limitLister is a MockLister that records the limits it's asked for
+type limitLister struct {
+	MockLister
+	limits []int
+}
+
+func (ll *limitLister) ListLimit(prefix string, limit int) ([]string, error) {
+	ll.limits = append(ll.limits, limit)
+	paths, err := ll.List(prefix)
+	if len(paths) > limit {
+		paths = paths[:limit]
+	}
+	return paths, err
+}
+
+func TestTablePrefixExists(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	lister := MockLister{Paths: []string{"s3://b/s/t/_data_timestamp_year=2015/a.json", "s3://b/s/tt/a.json"}}
+
+	exists, err := TablePrefixExists(lister, bucket, "s", "t")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	// s/tt/ isn't under s/t/
+	lister = MockLister{Paths: []string{"s3://b/s/tt/a.json"}}
+	exists, err = TablePrefixExists(lister, bucket, "s", "t")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	exists, err = TablePrefixExists(MockLister{}, bucket, "s", "t")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	// only one key is needed
+	limited := &limitLister{MockLister: MockLister{Paths: []string{"s3://b/s/t/a.json", "s3://b/s/t/b.json"}}}
+	exists, err = TablePrefixExists(limited, bucket, "s", "t")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, []int{1}, limited.limits)
+
+	_, err = TablePrefixExists(MockLister{Err: errors.New("access denied")}, bucket, "s", "t")
+	assert.EqualError(t, err, "error listing s3://b/s/t/: access denied")
+	_, err = TablePrefixExists(MockLister{}, bucket, "s", "t/..")
+	assert.Error(t, err)
+}
@@ -0,0 +1,77 @@
+package s3filepath
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LoadMode is how a LoadPlan loads its file into the target table
+type LoadMode int
+
+const (
+	// LoadAppend COPYs the file's rows straight into the target
+	LoadAppend LoadMode = iota
+	// LoadUpsert replaces the target's rows that have the same primary keys, see UpsertPlan
+	LoadUpsert
+)
+
+// LoadPlan loads an S3File into a table in one transaction, along with any statements that
+// have to run with it, so that a failure part way through doesn't leave a half loaded table.
+type LoadPlan struct {
+	File *S3File
+	// Target is the table to load into. It may be schema qualified; if it's empty
+	// the file's schema and table are used.
+	Target string
+	Mode   LoadMode
+	// PrimaryKeys are the columns that identify a row, for LoadUpsert
+	PrimaryKeys []string
+	// CopyOptions are used to COPY the file
+	CopyOptions CopyOptions
+	// PostLoad are statements to run after the load, in the same transaction.
+	// They can't include VACUUM, which Redshift won't run in a transaction; see MaintenanceSQL.
+	PostLoad []string
+}
+
+// Statements returns the SQL to run, in order: BEGIN, the COPY (for LoadAppend) or the
+// UpsertPlan's merge (for LoadUpsert), the PostLoad statements and COMMIT.
+// If any statement fails, ROLLBACK (e.g. with sql.Tx's Rollback) so none of them take effect.
+func (p LoadPlan) Statements() ([]string, error) {
+	if p.File == nil {
+		return nil, fmt.Errorf("load plan has no file")
+	}
+	var statements []string
+	switch p.Mode {
+	case LoadAppend:
+		if len(p.PrimaryKeys) > 0 {
+			return nil, fmt.Errorf("primary keys are only used when upserting")
+		}
+		copySQL, err := p.File.CopyCommand(p.Target, p.CopyOptions)
+		if err != nil {
+			return nil, err
+		}
+		statements = []string{copySQL}
+	case LoadUpsert:
+		merge, err := UpsertPlan{
+			File:        p.File,
+			Target:      p.Target,
+			PrimaryKeys: p.PrimaryKeys,
+			CopyOptions: p.CopyOptions,
+		}.mergeStatements()
+		if err != nil {
+			return nil, err
+		}
+		statements = merge
+	default:
+		return nil, fmt.Errorf("unknown load mode %d", p.Mode)
+	}
+	return inTransaction(append(statements, p.PostLoad...)), nil
+}
+
+// SQL returns the Statements as one script, each statement ending with a semicolon
+func (p LoadPlan) SQL() (string, error) {
+	statements, err := p.Statements()
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(statements, ";\n") + ";", nil
+}
@@ -0,0 +1,67 @@
+package s3filepath
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadPlanAppend(t *testing.T) {
+	f := buildS3File(testCopyBucket, "s", "t", "", expectedDate, "json.gz")
+	copySQL, err := f.CopyCommand("", CopyOptions{MaxError: 5})
+	assert.NoError(t, err)
+
+	plan := LoadPlan{
+		File:        f,
+		CopyOptions: CopyOptions{MaxError: 5},
+		PostLoad:    []string{`DELETE FROM "s"."t" WHERE ts < GETDATE() - 30`},
+	}
+	statements, err := plan.Statements()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"BEGIN", copySQL, `DELETE FROM "s"."t" WHERE ts < GETDATE() - 30`, "COMMIT"}, statements)
+
+	sql, err := plan.SQL()
+	assert.NoError(t, err)
+	assert.Equal(t, "BEGIN;\n"+copySQL+";\n"+`DELETE FROM "s"."t" WHERE ts < GETDATE() - 30;`+"\nCOMMIT;", sql)
+
+	plan.PrimaryKeys = []string{"id"}
+	_, err = plan.Statements()
+	assert.Error(t, err)
+}
+
+func TestLoadPlanUpsert(t *testing.T) {
+	f := buildS3File(testCopyBucket, "s", "t", "", expectedDate, "json.gz")
+	upsert, err := UpsertPlan{File: f, Target: "s.target", PrimaryKeys: []string{"id"}}.Statements()
+	assert.NoError(t, err)
+
+	statements, err := LoadPlan{
+		File:        f,
+		Target:      "s.target",
+		Mode:        LoadUpsert,
+		PrimaryKeys: []string{"id"},
+		PostLoad:    []string{"ANALYZE \"s\".\"target\""},
+	}.Statements()
+	assert.NoError(t, err)
+	// the upsert's own transaction isn't nested, the post load statements are in it
+	expected := append(append([]string{}, upsert[:len(upsert)-1]...), "ANALYZE \"s\".\"target\"", "COMMIT")
+	assert.Equal(t, expected, statements)
+	assert.Equal(t, "BEGIN", statements[0])
+	assert.Equal(t, 1, countStatement(statements, "BEGIN"))
+
+	_, err = LoadPlan{File: f, Mode: LoadUpsert}.Statements()
+	assert.Error(t, err)
+	_, err = LoadPlan{Mode: LoadAppend}.Statements()
+	assert.Error(t, err)
+	_, err = LoadPlan{File: f, Mode: LoadMode(5)}.Statements()
+	assert.Error(t, err)
+}
+
+func countStatement(statements []string, statement string) int {
+	n := 0
+	for _, s := range statements {
+		if s == statement {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,49 @@
+package s3filepath
+
+import "sync"
+
+// Logger receives events about what CreateS3File is doing, to help debug failed lookups.
+// It's a single method so it's easy to adapt to any logging library, e.g. for kayvee:
+//
+//	type kvLogger struct{ l logger.KayveeLogger }
+//
+//	func (k kvLogger) Log(event string, fields map[string]interface{}) { k.l.InfoD(event, fields) }
+//
+// The events are:
+//
+//	s3file-lookup: a data file was looked for, with its "path" and "suffix" and whether it was "found"
+//	s3file-lookup-error: looking for a data file failed, with its "path", "suffix" and the "error"
+//	s3file-found: the search is over and found the file at "path"
+//	s3file-not-found: the search is over without finding anything, with the "candidates" tried
+//	s3file-exists-error: S3PathChecker.FileExists hit an error at "path" and returned false
+//	s3file-already-deleted: S3File.Delete found there was nothing to delete at "path"
+type Logger interface {
+	Log(event string, fields map[string]interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Log(string, map[string]interface{}) {}
+
+var (
+	loggerMu sync.RWMutex
+	logger   Logger = nopLogger{}
+)
+
+// SetLogger sets the Logger for the package. It's safe to call while lookups are running,
+// though lookups already in progress may still log to the old Logger.
+// Passing nil turns logging back off.
+func SetLogger(l Logger) {
+	if l == nil {
+		l = nopLogger{}
+	}
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
+	logger = l
+}
+
+func currentLogger() Logger {
+	loggerMu.RLock()
+	defer loggerMu.RUnlock()
+	return logger
+}
@@ -0,0 +1,64 @@
+package s3filepath
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type loggedEvent struct {
+	event  string
+	fields map[string]interface{}
+}
+
+type recordingLogger struct {
+	events []loggedEvent
+}
+
+func (l *recordingLogger) Log(event string, fields map[string]interface{}) {
+	l.events = append(l.events, loggedEvent{event, fields})
+}
+
+func TestLoggerFound(t *testing.T) {
+	l := &recordingLogger{}
+	SetLogger(l)
+	defer SetLogger(nil)
+
+	bucket := S3Bucket{Name: "b"}
+	prefix := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z"
+	pc := MockPathChecker{map[string]bool{prefix + ".json.gz": true}}
+	_, err := CreateS3File(pc, bucket, "s", "t", "", expectedDate)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []loggedEvent{
+		{"s3file-lookup", map[string]interface{}{"path": prefix + ".manifest", "suffix": "manifest", "found": false}},
+		{"s3file-lookup", map[string]interface{}{"path": prefix + ".json.gz", "suffix": "json.gz", "found": true}},
+		{"s3file-found", map[string]interface{}{"path": prefix + ".json.gz"}},
+	}, l.events)
+}
+
+func TestLoggerNotFound(t *testing.T) {
+	l := &recordingLogger{}
+	SetLogger(l)
+	defer SetLogger(nil)
+
+	bucket := S3Bucket{Name: "b"}
+	_, err := CreateS3File(MockPathChecker{}, bucket, "s", "t", "", expectedDate)
+	assert.Error(t, err)
+
+	candidates := CandidateDataPaths(bucket, "s", "t", expectedDate)
+	if assert.Len(t, l.events, len(candidates)+1) {
+		for i, candidate := range candidates {
+			assert.Equal(t, "s3file-lookup", l.events[i].event)
+			assert.Equal(t, candidate, l.events[i].fields["path"])
+			assert.Equal(t, false, l.events[i].fields["found"])
+		}
+		assert.Equal(t, loggedEvent{"s3file-not-found", map[string]interface{}{"candidates": candidates}},
+			l.events[len(candidates)])
+	}
+}
+
+func TestSetLoggerNil(t *testing.T) {
+	SetLogger(nil)
+	logger.Log("anything", nil) // doesn't panic
+}
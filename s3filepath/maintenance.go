@@ -0,0 +1,56 @@
+package s3filepath
+
+import "fmt"
+
+// VacuumMode is which kind of VACUUM MaintenanceSQL runs
+type VacuumMode int
+
+const (
+	// VacuumFull sorts the table and reclaims the space of deleted rows, like a plain VACUUM
+	VacuumFull VacuumMode = iota
+	// VacuumSortOnly sorts the table without reclaiming space
+	VacuumSortOnly
+	// VacuumDeleteOnly reclaims space without sorting the table
+	VacuumDeleteOnly
+)
+
+// String returns the VACUUM keyword for the mode, or VacuumMode(n) if it isn't one
+func (m VacuumMode) String() string {
+	switch m {
+	case VacuumFull:
+		return "FULL"
+	case VacuumSortOnly:
+		return "SORT ONLY"
+	case VacuumDeleteOnly:
+		return "DELETE ONLY"
+	default:
+		return fmt.Sprintf("VacuumMode(%d)", int(m))
+	}
+}
+
+// MaintenanceOptions control the statements MaintenanceSQL returns.
+// The zero value runs a full VACUUM and then an ANALYZE.
+type MaintenanceOptions struct {
+	VacuumMode  VacuumMode
+	SkipVacuum  bool
+	SkipAnalyze bool
+}
+
+// MaintenanceSQL returns the VACUUM and ANALYZE statements to run on the table after a big load,
+// in that order, so the statistics are gathered on the vacuumed table.
+// VACUUM can't run inside a transaction, so these have to be run on their own.
+// It's an error if the VacuumMode isn't one of the ones above.
+func MaintenanceSQL(schema, table string, opts MaintenanceOptions) ([]string, error) {
+	name := quoteIdent(schema) + "." + quoteIdent(table)
+	var sql []string
+	if !opts.SkipVacuum {
+		if opts.VacuumMode < VacuumFull || opts.VacuumMode > VacuumDeleteOnly {
+			return nil, fmt.Errorf("unknown vacuum mode %d", int(opts.VacuumMode))
+		}
+		sql = append(sql, "VACUUM "+opts.VacuumMode.String()+" "+name)
+	}
+	if !opts.SkipAnalyze {
+		sql = append(sql, "ANALYZE "+name)
+	}
+	return sql, nil
+}
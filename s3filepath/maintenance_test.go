@@ -0,0 +1,37 @@
+package s3filepath
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintenanceSQL(t *testing.T) {
+	for _, test := range []struct {
+		opts     MaintenanceOptions
+		expected []string
+	}{
+		{MaintenanceOptions{}, []string{`VACUUM FULL "s"."t"`, `ANALYZE "s"."t"`}},
+		{MaintenanceOptions{VacuumMode: VacuumSortOnly}, []string{`VACUUM SORT ONLY "s"."t"`, `ANALYZE "s"."t"`}},
+		{MaintenanceOptions{VacuumMode: VacuumDeleteOnly}, []string{`VACUUM DELETE ONLY "s"."t"`, `ANALYZE "s"."t"`}},
+		{MaintenanceOptions{SkipAnalyze: true}, []string{`VACUUM FULL "s"."t"`}},
+		{MaintenanceOptions{SkipVacuum: true, VacuumMode: VacuumSortOnly}, []string{`ANALYZE "s"."t"`}},
+		{MaintenanceOptions{SkipVacuum: true, SkipAnalyze: true}, nil},
+	} {
+		sql, err := MaintenanceSQL("s", "t", test.opts)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, sql, "%+v", test.opts)
+	}
+
+	_, err := MaintenanceSQL("s", "t", MaintenanceOptions{VacuumMode: VacuumMode(7)})
+	assert.EqualError(t, err, "unknown vacuum mode 7")
+	_, err = MaintenanceSQL("s", "t", MaintenanceOptions{VacuumMode: VacuumMode(-1)})
+	assert.Error(t, err)
+	// a mode that isn't used is fine
+	sql, err := MaintenanceSQL("s", "t", MaintenanceOptions{VacuumMode: VacuumMode(7), SkipVacuum: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`ANALYZE "s"."t"`}, sql)
+
+	assert.Equal(t, "SORT ONLY", VacuumSortOnly.String())
+	assert.Equal(t, "VacuumMode(7)", VacuumMode(7).String())
+}
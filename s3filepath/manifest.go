@@ -0,0 +1,143 @@
+package s3filepath
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Manifest is a Redshift COPY manifest, listing the data files to load
+// See: https://docs.aws.amazon.com/redshift/latest/dg/loading-data-files-using-manifest.html
+type Manifest struct {
+	Entries []ManifestEntry `json:"entries"`
+}
+
+// ManifestEntry is one data file in a Manifest
+type ManifestEntry struct {
+	URL       string `json:"url"`
+	Mandatory bool   `json:"mandatory"`
+}
+
+// ParseManifest reads a manifest like the ones GenerateManifest makes.
+// It's an error if the JSON is malformed or an entry has no url.
+func ParseManifest(reader io.Reader) (*Manifest, error) {
+	var manifest Manifest
+	if err := json.NewDecoder(reader).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("error parsing manifest: %w", err)
+	}
+	for i, entry := range manifest.Entries {
+		if entry.URL == "" {
+			return nil, fmt.Errorf("manifest entry %d has no url", i)
+		}
+	}
+	return &manifest, nil
+}
+
+// DataPaths returns the url of every entry in the manifest, in order
+func (m *Manifest) DataPaths() []string {
+	paths := make([]string, 0, len(m.Entries))
+	for _, entry := range m.Entries {
+		paths = append(paths, entry.URL)
+	}
+	return paths
+}
+
+// IsManifest returns whether the S3File's data file is a manifest rather than the data itself
+func (f *S3File) IsManifest() bool {
+	return isManifest(f.Suffix)
+}
+
+// GenerateManifest lists the S3File's subfolder and returns a manifest of every data file straight
+// in it with the S3File's suffix, e.g. part-00000.json.gz, part-00001.json.gz, ... for "json.gz".
+// This lets us COPY files that were written in parts rather than as one big file. Data files are
+// parts (see FindParts) or files whose names start with the data file's, like UNLOAD's
+// schema_table_date_0000_part_00, so other files such as _SUCCESS or a README are left out.
+// Every entry is mandatory, see GenerateManifestWithMandatory.
+func GenerateManifest(lister Lister, f *S3File) ([]byte, error) {
+	return GenerateManifestWithMandatory(lister, f, true)
+}
+
+// GenerateManifestWithMandatory is GenerateManifest with every entry's mandatory flag set to mandatory.
+// Redshift fails a COPY when a mandatory file is missing, but skips missing ones that aren't,
+// so use false for reloads where some of the parts may since have been cleaned up.
+func GenerateManifestWithMandatory(lister Lister, f *S3File, mandatory bool) ([]byte, error) {
+	if isManifest(f.Suffix) {
+		return nil, fmt.Errorf("can't generate a manifest for a manifest file: %s", f.GetDataFilename())
+	}
+	paths, err := lister.List(f.folder())
+	if err != nil {
+		return nil, fmt.Errorf("error listing %s: %w", f.folder(), err)
+	}
+
+	var manifest Manifest
+	sort.Strings(paths)
+	for _, p := range paths {
+		if f.isDataPart(p) {
+			manifest.Entries = append(manifest.Entries, ManifestEntry{URL: p, Mandatory: mandatory})
+		}
+	}
+	if len(manifest.Entries) == 0 {
+		return nil, fmt.Errorf("no %q files found under %s", f.Suffix, f.folder())
+	}
+	return json.Marshal(manifest)
+}
+
+// WriteManifest writes a manifest of the entries, all mandatory, next to the S3File's data file,
+// e.g. s3://bucket/schema/table/.../schema_table_date.manifest, and returns its path.
+// The S3File's Suffix is ignored, so its data file can be one of the entries.
+func (f *S3File) WriteManifest(w Writer, entries []string) (string, error) {
+	manifestEntries := make([]ManifestEntry, 0, len(entries))
+	for _, entry := range entries {
+		manifestEntries = append(manifestEntries, ManifestEntry{URL: entry, Mandatory: true})
+	}
+	return f.WriteManifestEntries(w, manifestEntries)
+}
+
+// WriteManifestEntries is WriteManifest with each entry's mandatory flag set by the caller
+func (f *S3File) WriteManifestEntries(w Writer, entries []ManifestEntry) (string, error) {
+	if len(entries) == 0 {
+		return "", fmt.Errorf("can't write a manifest without any entries")
+	}
+	for i, entry := range entries {
+		if entry.URL == "" {
+			return "", fmt.Errorf("manifest entry %d has no url", i)
+		}
+	}
+	data, err := json.Marshal(Manifest{Entries: entries})
+	if err != nil {
+		return "", err
+	}
+	manifestPath := f.GetDataPrefix() + ".manifest"
+	if err := w.Write(manifestPath, data); err != nil {
+		return "", fmt.Errorf("error writing manifest %s: %w", manifestPath, err)
+	}
+	return manifestPath, nil
+}
+
+// folder returns the full s3 path of the S3File's subfolder, with a trailing slash
+func (f *S3File) folder() string {
+	return strings.TrimSuffix(s3Path(f.Bucket, f.Subfolder), "/") + "/"
+}
+
+// isDataPart returns whether the path is one of the S3File's data files: straight in its folder,
+// with its suffix, and named like a part or like its data file
+func (f *S3File) isDataPart(p string) bool {
+	name := strings.TrimPrefix(p, f.folder())
+	if name == p || strings.Contains(name, "/") || fileSuffix(name) != normalizeSuffix(f.Suffix) {
+		return false
+	}
+	name = strings.TrimSuffix(name, formatSuffix(f.Suffix))
+	return partRegex.MatchString(name) || strings.HasPrefix(name, path.Base(f.GetDataPrefix()))
+}
+
+// fileSuffix returns everything after the first dot in the file's name
+func fileSuffix(p string) string {
+	base := path.Base(p)
+	if i := strings.Index(base, "."); i >= 0 {
+		return base[i+1:]
+	}
+	return ""
+}
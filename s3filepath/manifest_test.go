@@ -0,0 +1,166 @@
+package s3filepath
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateManifest(t *testing.T) {
+	folder := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/"
+	lister := MockLister{Paths: []string{
+		folder + "part-00001.json.gz",
+		folder + "part-00000.json.gz",
+		folder + "part-00000.json",
+		folder + "config_s_t_2015-11-10T23:00:00Z.yml",
+		"s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=11/part-00000.json.gz",
+	}}
+
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json.gz")
+	manifest, err := GenerateManifest(lister, f)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"entries":[`+
+		`{"url":"`+folder+`part-00000.json.gz","mandatory":true},`+
+		`{"url":"`+folder+`part-00001.json.gz","mandatory":true}]}`, string(manifest))
+
+	f = buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "parquet")
+	_, err = GenerateManifest(lister, f)
+	assert.Error(t, err)
+
+	f = buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "manifest")
+	_, err = GenerateManifest(lister, f)
+	assert.Error(t, err)
+
+	f = buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json.gz")
+	denied := errors.New("access denied")
+	_, err = GenerateManifest(MockLister{Err: denied}, f)
+	assert.True(t, errors.Is(err, denied))
+}
+
+func TestParseManifest(t *testing.T) {
+	m, err := ParseManifest(strings.NewReader(`{"entries": [
+		{"url": "s3://b/s/t/part-00000.json.gz", "mandatory": true},
+		{"url": "s3://b/s/t/part-00001.json.gz", "mandatory": false}
+	]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"s3://b/s/t/part-00000.json.gz", "s3://b/s/t/part-00001.json.gz"}, m.DataPaths())
+	assert.True(t, m.Entries[0].Mandatory)
+	assert.False(t, m.Entries[1].Mandatory)
+
+	// it reads what GenerateManifest writes
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json.gz")
+	lister := MockLister{Paths: []string{f.folder() + "part-00000.json.gz"}}
+	generated, err := GenerateManifest(lister, f)
+	assert.NoError(t, err)
+	m, err = ParseManifest(bytes.NewReader(generated))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{f.folder() + "part-00000.json.gz"}, m.DataPaths())
+
+	_, err = ParseManifest(strings.NewReader(`{"entries": [`))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "error parsing manifest")
+	_, err = ParseManifest(strings.NewReader(`{"entries": [{"mandatory": true}]}`))
+	assert.EqualError(t, err, "manifest entry 0 has no url")
+}
+
+func TestGenerateManifestSkipsOtherFiles(t *testing.T) {
+	folder := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/"
+	lister := MockLister{Paths: []string{
+		folder + "README",
+		folder + "_SUCCESS",
+		folder + "nested/part-00002",
+		folder + "nested/s_t_2015-11-10T23:00:00Z_0000_part_00",
+		folder + "s_t_2015-11-10T23:00:00Z_0001_part_00",
+		folder + "s_t_2015-11-10T23:00:00Z_0000_part_00",
+		folder + "part-00000",
+		folder + "s_t_2015-11-10T23:00:00Z.json.gz",
+	}}
+
+	// csv with no suffix, e.g. from UNLOAD
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "")
+	manifest, err := GenerateManifest(lister, f)
+	assert.NoError(t, err)
+	m, err := ParseManifest(bytes.NewReader(manifest))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		folder + "part-00000",
+		folder + "s_t_2015-11-10T23:00:00Z_0000_part_00",
+		folder + "s_t_2015-11-10T23:00:00Z_0001_part_00",
+	}, m.DataPaths())
+
+	_, err = GenerateManifest(MockLister{Paths: []string{folder + "README", folder + "_SUCCESS"}}, f)
+	assert.Error(t, err)
+}
+
+func TestGenerateManifestWithMandatory(t *testing.T) {
+	folder := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/"
+	lister := MockLister{Paths: []string{folder + "part-00000.json.gz", folder + "part-00001.json.gz"}}
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json.gz")
+
+	manifest, err := GenerateManifestWithMandatory(lister, f, false)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"entries":[`+
+		`{"url":"`+folder+`part-00000.json.gz","mandatory":false},`+
+		`{"url":"`+folder+`part-00001.json.gz","mandatory":false}]}`, string(manifest))
+
+	manifest, err = GenerateManifestWithMandatory(lister, f, true)
+	assert.NoError(t, err)
+	m, err := ParseManifest(bytes.NewReader(manifest))
+	assert.NoError(t, err)
+	for _, entry := range m.Entries {
+		assert.True(t, entry.Mandatory)
+	}
+}
+
+func TestIsManifest(t *testing.T) {
+	assert.True(t, buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "manifest").IsManifest())
+	assert.False(t, buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json.gz").IsManifest())
+}
+
+func TestWriteManifest(t *testing.T) {
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json.gz")
+	entries := []string{f.folder() + "part-00000.json.gz", f.folder() + "part-00001.json.gz"}
+	w := &MockWriter{}
+	manifestPath, err := f.WriteManifest(w, entries)
+	assert.NoError(t, err)
+	assert.Equal(t, "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z.manifest",
+		manifestPath)
+
+	m, err := ParseManifest(bytes.NewReader(w.Files[manifestPath]))
+	assert.NoError(t, err)
+	assert.Equal(t, entries, m.DataPaths())
+	assert.True(t, m.Entries[1].Mandatory)
+
+	_, err = f.WriteManifest(w, nil)
+	assert.Error(t, err)
+	_, err = f.WriteManifest(&MockWriter{Err: errors.New("access denied")}, entries)
+	assert.EqualError(t, err, "error writing manifest "+manifestPath+": access denied")
+}
+
+func TestWriteManifestEntries(t *testing.T) {
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json.gz")
+	entries := []ManifestEntry{
+		{URL: f.folder() + "part-00000.json.gz", Mandatory: true},
+		{URL: f.folder() + "part-00001.json.gz", Mandatory: false},
+		{URL: f.folder() + "part-00002.json.gz", Mandatory: true},
+	}
+	w := &MockWriter{}
+	manifestPath, err := f.WriteManifestEntries(w, entries)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"entries":[`+
+		`{"url":"`+f.folder()+`part-00000.json.gz","mandatory":true},`+
+		`{"url":"`+f.folder()+`part-00001.json.gz","mandatory":false},`+
+		`{"url":"`+f.folder()+`part-00002.json.gz","mandatory":true}]}`, string(w.Files[manifestPath]))
+
+	m, err := ParseManifest(bytes.NewReader(w.Files[manifestPath]))
+	assert.NoError(t, err)
+	assert.Equal(t, entries, m.Entries)
+
+	_, err = f.WriteManifestEntries(w, nil)
+	assert.Error(t, err)
+	_, err = f.WriteManifestEntries(w, []ManifestEntry{{Mandatory: true}})
+	assert.EqualError(t, err, "manifest entry 0 has no url")
+}
@@ -0,0 +1,50 @@
+package s3filepath
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Metrics is told about every existence check the package makes, so that the volume and
+// latency of S3 calls can be tracked without the package depending on a metrics library.
+// CreateS3Files calls it from several goroutines at once, so it must be safe for concurrent use.
+type Metrics interface {
+	// ObserveLookup is called after looking for the data file with the given suffix.
+	// found is false if the lookup failed as well as if the file isn't there.
+	ObserveLookup(suffix string, found bool, dur time.Duration)
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) ObserveLookup(string, bool, time.Duration) {}
+
+var (
+	metricsMu sync.RWMutex
+	metrics   Metrics = nopMetrics{}
+)
+
+// SetMetrics sets the Metrics for the package. Like SetLogger, it's safe to call while
+// lookups are running. Passing nil turns metrics back off.
+func SetMetrics(m Metrics) {
+	if m == nil {
+		m = nopMetrics{}
+	}
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	metrics = m
+}
+
+func currentMetrics() Metrics {
+	metricsMu.RLock()
+	defer metricsMu.RUnlock()
+	return metrics
+}
+
+// observedLookup is lookup, reporting how long it took to the package Metrics
+func observedLookup(ctx context.Context, pc PathChecker, path, suffix string) (bool, S3ObjectInfo, error) {
+	start := time.Now()
+	exists, info, err := lookup(ctx, pc, path)
+	currentMetrics().ObserveLookup(suffix, exists, time.Since(start))
+	return exists, info, err
+}
@@ -0,0 +1,69 @@
+package s3filepath
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type observation struct {
+	suffix string
+	found  bool
+}
+
+type recordingMetrics struct {
+	mu           sync.Mutex
+	observations []observation
+}
+
+func (m *recordingMetrics) ObserveLookup(suffix string, found bool, dur time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observations = append(m.observations, observation{suffix, found})
+}
+
+func TestMetricsCreateS3File(t *testing.T) {
+	m := &recordingMetrics{}
+	SetMetrics(m)
+	defer SetMetrics(nil)
+
+	prefix := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z"
+	pc := MockPathChecker{map[string]bool{prefix + ".json": true}}
+	_, err := CreateS3File(pc, S3Bucket{Name: "b"}, "s", "t", "", expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, []observation{
+		{"manifest", false},
+		{"json.gz", false},
+		{"json", true},
+	}, m.observations)
+}
+
+func TestMetricsCreateS3Files(t *testing.T) {
+	m := &recordingMetrics{}
+	SetMetrics(m)
+	defer SetMetrics(nil)
+
+	requests := []FileRequest{
+		{Schema: "s", Table: "a", Date: expectedDate},
+		{Schema: "s", Table: "b", Date: expectedDate},
+		{Schema: "s", Table: "c", Date: expectedDate},
+	}
+	_, errs := CreateS3FilesConcurrently(MockPathChecker{}, S3Bucket{Name: "b"}, requests, 2)
+	for _, err := range errs {
+		assert.Error(t, err)
+	}
+	// nothing exists, so every suffix is tried for every request
+	assert.Len(t, m.observations, len(requests)*len(DefaultSuffixes()))
+}
+
+func TestMetricsFindS3Files(t *testing.T) {
+	m := &recordingMetrics{}
+	SetMetrics(m)
+	defer SetMetrics(nil)
+
+	_, err := FindS3Files(MockPathChecker{}, S3Bucket{Name: "b"}, "s", "t", expectedDate)
+	assert.Error(t, err)
+	assert.Len(t, m.observations, len(DefaultSuffixes()))
+}
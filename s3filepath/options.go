@@ -0,0 +1,252 @@
+package s3filepath
+
+import (
+	"path"
+	"time"
+)
+
+// S3FileOption customizes an S3File built by NewS3File
+type S3FileOption func(*S3File)
+
+// PartitionGranularity is how finely the default partition folders split up the data
+type PartitionGranularity int
+
+const (
+	// PartitionDay is the default: year, month and day folders
+	PartitionDay PartitionGranularity = iota
+	// PartitionHour adds a _data_timestamp_hour= folder under the day, using the UTC hour
+	PartitionHour
+)
+
+// PartitionKeys are the names of the partition folders in the default Subfolder, each followed by
+// "=" and the zero padded number, e.g. _data_timestamp_year=2015. Empty names are the defaults.
+type PartitionKeys struct {
+	Year  string
+	Month string
+	Day   string
+	// Hour is only used with PartitionHour
+	Hour string
+}
+
+// DefaultPartitionKeys are the partition folder names used unless WithPartitionKeys says otherwise
+var DefaultPartitionKeys = PartitionKeys{
+	Year:  "_data_timestamp_year",
+	Month: "_data_timestamp_month",
+	Day:   "_data_timestamp_day",
+	Hour:  "_data_timestamp_hour",
+}
+
+// HivePartitionKeys are the year=/month=/day=/hour= names Hive style data lakes use
+var HivePartitionKeys = PartitionKeys{Year: "year", Month: "month", Day: "day", Hour: "hour"}
+
+// withDefaults fills in any empty name from DefaultPartitionKeys
+func (k PartitionKeys) withDefaults() PartitionKeys {
+	if k.Year == "" {
+		k.Year = DefaultPartitionKeys.Year
+	}
+	if k.Month == "" {
+		k.Month = DefaultPartitionKeys.Month
+	}
+	if k.Day == "" {
+		k.Day = DefaultPartitionKeys.Day
+	}
+	if k.Hour == "" {
+		k.Hour = DefaultPartitionKeys.Hour
+	}
+	return k
+}
+
+// WithDate sets the DataDate. It's converted to UTC like it is in CreateS3File.
+func WithDate(date time.Time) S3FileOption {
+	return func(f *S3File) {
+		f.DataDate = date
+	}
+}
+
+// WithSubfolder sets the folder in the bucket the data file is in,
+// instead of the default schema/table/year/month/day partition folders
+func WithSubfolder(subfolder string) S3FileOption {
+	return func(f *S3File) {
+		f.Subfolder = subfolder
+	}
+}
+
+// WithExplicitSubfolder sets the folder in the bucket the data file is in verbatim, for tables
+// at a custom location that has nothing to do with the schema, table or date. Unlike WithSubfolder
+// an empty subfolder is used as is, putting the data and config files at the root of the bucket
+// (or its Prefix), and the partition options have no effect.
+func WithExplicitSubfolder(subfolder string) S3FileOption {
+	return func(f *S3File) {
+		f.Subfolder = subfolder
+		f.explicitSubfolder = true
+	}
+}
+
+// WithFlatLayout puts the data file straight in schema/table/, for buckets that don't
+// use the _data_timestamp_year=/month=/day= partition folders
+func WithFlatLayout() S3FileOption {
+	return func(f *S3File) {
+		f.Subfolder = path.Join(f.Schema, f.Table)
+	}
+}
+
+// WithPartitionGranularity sets how finely the default partition folders are split up.
+// It has no effect with WithSubfolder or WithFlatLayout.
+func WithPartitionGranularity(granularity PartitionGranularity) S3FileOption {
+	return func(f *S3File) {
+		f.granularity = granularity
+	}
+}
+
+// WithPartitionKeys sets the names of the default partition folders, e.g. HivePartitionKeys.
+// It has no effect with WithSubfolder or WithFlatLayout.
+func WithPartitionKeys(keys PartitionKeys) S3FileOption {
+	return func(f *S3File) {
+		f.partitionKeys = keys
+	}
+}
+
+// WithUppercaseSuffixes makes CreateS3FileWithOptions also look for the uppercase version of each
+// suffix, e.g. .JSON.GZ after .json.gz, for producers that write uppercase suffixes. S3 keys are
+// case sensitive and files are looked for by their exact key, so mixed case suffixes like
+// .Json.gz still won't be found. It's opt-in since it doubles the lookups for missing files.
+func WithUppercaseSuffixes() S3FileOption {
+	return func(f *S3File) {
+		f.uppercaseSuffixes = true
+	}
+}
+
+// WithExtendedSuffixes makes CreateS3FileWithOptions look for ExtendedSuffixes rather than the
+// default ones, so it also finds bzip2, zstd and parquet files. It's opt-in since the COPY run on
+// the file has to know how it's compressed and formatted (see Compression, Format and CopyCommand),
+// which the main binary's COPY doesn't.
+func WithExtendedSuffixes() S3FileOption {
+	return func(f *S3File) {
+		f.extendedSuffixes = true
+	}
+}
+
+// ConfigNamer returns the name of a table's config file for the date, e.g. "schema.table.config.yaml".
+// The name is joined onto the data file's Subfolder. The date is always in UTC.
+type ConfigNamer func(schema, table string, date time.Time) string
+
+// WithConfigNamer names the generated config file with namer instead of config_schema_table_date.yml.
+// It has no effect with WithConfFile. CreateS3FileWithOptions doesn't look for the .yaml variant
+// of a custom name, the config file is assumed to be exactly where namer says.
+func WithConfigNamer(namer ConfigNamer) S3FileOption {
+	return func(f *S3File) {
+		f.configNamer = namer
+	}
+}
+
+// WithRequireConfig makes CreateS3FileWithOptions check that the config file exists once it's found
+// the data file, returning an error matching ErrConfigNotFound if it doesn't. Without it ConfFile
+// is set to the generated default whether or not it's there.
+func WithRequireConfig() S3FileOption {
+	return func(f *S3File) {
+		f.requireConfig = true
+	}
+}
+
+// DefaultSeparator goes between the schema, table and date in filenames unless WithSeparator says otherwise
+const DefaultSeparator = "_"
+
+// WithSeparator puts separator between the parts of the data and config filenames instead of
+// DefaultSeparator, e.g. "." for schema.table.date.json and config.schema.table.date.yml.
+// Parse such files with ParseDataFilenameWithSeparator.
+func WithSeparator(separator string) S3FileOption {
+	return func(f *S3File) {
+		f.separator = separator
+	}
+}
+
+// WithoutSchemaPrefix leaves the schema out of the data and config filenames, e.g.
+// s3://bucket/schema/table/.../table_date.json.gz, for producers where the folder says what
+// the schema is. The schema is still part of the default Subfolder.
+func WithoutSchemaPrefix() S3FileOption {
+	return func(f *S3File) {
+		f.withoutSchemaPrefix = true
+	}
+}
+
+// WithAmbiguityCheck makes CreateS3FileWithOptions check, when it finds a manifest, that none of
+// the other suffixes have a data file too, returning an AmbiguousFileError with all their paths
+// if they do. It costs a lookup per remaining suffix whenever a manifest is found.
+func WithAmbiguityCheck() S3FileOption {
+	return func(f *S3File) {
+		f.ambiguityCheck = true
+	}
+}
+
+// WithReadBufferSize sets how much of the file EstimateRows and VerifyChecksum read at a time,
+// instead of DefaultReadBufferSize. It isn't kept when the S3File is marshalled to JSON.
+func WithReadBufferSize(size int) S3FileOption {
+	return func(f *S3File) {
+		f.readBufferSize = size
+	}
+}
+
+// WithConfFile sets the config file instead of the generated one next to the data file
+func WithConfFile(confFile string) S3FileOption {
+	return func(f *S3File) {
+		f.ConfFile = confFile
+	}
+}
+
+// WithSuffix sets the data file's suffix, e.g. "json.gz"
+func WithSuffix(suffix string) S3FileOption {
+	return func(f *S3File) {
+		f.Suffix = suffix
+	}
+}
+
+// WithDateLayout sets how the date is formatted in filenames, see S3File.DateLayout
+func WithDateLayout(dateLayout string) S3FileOption {
+	return func(f *S3File) {
+		f.DateLayout = dateLayout
+	}
+}
+
+// NewS3File builds an S3File for the table from the given options, without checking
+// whether it exists. Anything not set by an option gets the same default CreateS3File
+// would use: the partition folders for the date as the Subfolder and the generated
+// config file as the ConfFile.
+// It returns an error if the schema or table isn't a valid name, see validateName,
+// or WithSeparator was given an invalid separator.
+func NewS3File(bucket S3Bucket, schema, table string, opts ...S3FileOption) (*S3File, error) {
+	if err := validateNames(schema, table); err != nil {
+		return nil, err
+	}
+	f := newS3File(bucket, schema, table, opts...)
+	if f.separator != "" {
+		if err := validateSeparator(f.separator); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// newS3File is NewS3File without validating the schema and table names
+func newS3File(bucket S3Bucket, schema, table string, opts ...S3FileOption) *S3File {
+	f := &S3File{
+		Bucket: bucket,
+		Schema: schema,
+		Table:  table,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	// partitions are always in UTC, whatever location the date was given in
+	f.DataDate = f.DataDate.UTC()
+	if f.separator == DefaultSeparator {
+		// so files with the default separator look the same however they were built
+		f.separator = ""
+	}
+	if f.Subfolder == "" && !f.explicitSubfolder {
+		f.Subfolder = partitionPath(schema, table, f.DataDate, f.partitionKeys, f.granularity)
+	}
+	if f.ConfFile == "" {
+		f.ConfFile = f.GetConfigFilename()
+	}
+	return f
+}
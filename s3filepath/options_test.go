@@ -0,0 +1,379 @@
+package s3filepath
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewS3File(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+
+	// the defaults match what CreateS3File builds
+	f, err := NewS3File(bucket, "s", "t", WithDate(expectedDate), WithSuffix("json.gz"))
+	assert.NoError(t, err)
+	assert.Equal(t, buildS3File(bucket, "s", "t", "", expectedDate, "json.gz"), f)
+	assert.Equal(t, "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z.json.gz",
+		f.GetDataFilename())
+
+	f, err = NewS3File(bucket, "s", "t",
+		WithDate(expectedDate.In(time.FixedZone("PST", -8*60*60))),
+		WithSubfolder("exports/t"),
+		WithConfFile("s3://b/conf.yml"),
+		WithSuffix("json"),
+		WithDateLayout("20060102"))
+	assert.NoError(t, err)
+	assert.Equal(t, "s3://b/exports/t/s_t_20151110.json", f.GetDataFilename())
+	assert.Equal(t, "s3://b/conf.yml", f.ConfFile)
+	assert.Equal(t, time.UTC, f.DataDate.Location())
+
+	// the generated config file follows a custom subfolder
+	f, err = NewS3File(bucket, "s", "t", WithDate(expectedDate), WithSubfolder("exports/t"))
+	assert.NoError(t, err)
+	assert.Equal(t, "s3://b/exports/t/config_s_t_2015-11-10T23:00:00Z.yml", f.ConfFile)
+}
+
+func TestFlatLayout(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	f, err := NewS3File(bucket, "s", "t", WithDate(expectedDate), WithSuffix("json"), WithFlatLayout())
+	assert.NoError(t, err)
+	assert.Equal(t, "s/t", f.Subfolder)
+	assert.Equal(t, "s3://b/s/t/s_t_2015-11-10T23:00:00Z.json", f.GetDataFilename())
+	assert.Equal(t, "s3://b/s/t/config_s_t_2015-11-10T23:00:00Z.yml", f.ConfFile)
+
+	flatPath := "s3://b/s/t/s_t_2015-11-10T23:00:00Z.json.gz"
+	partitionedPath := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z.json.gz"
+
+	// each layout only finds its own files
+	pc := MockPathChecker{map[string]bool{flatPath: true}}
+	f, err = CreateS3FileWithOptions(context.Background(), pc, bucket, "s", "t", "", expectedDate, WithFlatLayout())
+	assert.NoError(t, err)
+	assert.Equal(t, flatPath, f.GetDataFilename())
+	assert.Equal(t, "json.gz", f.Suffix)
+	_, err = CreateS3File(pc, bucket, "s", "t", "", expectedDate)
+	assert.Error(t, err)
+
+	pc = MockPathChecker{map[string]bool{partitionedPath: true}}
+	f, err = CreateS3FileWithOptions(context.Background(), pc, bucket, "s", "t", "", expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, partitionedPath, f.GetDataFilename())
+	_, err = CreateS3FileWithOptions(context.Background(), pc, bucket, "s", "t", "", expectedDate, WithFlatLayout())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), flatPath)
+}
+
+func TestPartitionGranularity(t *testing.T) {
+	date := time.Date(2015, time.November, 10, 14, 30, 0, 0, time.UTC)
+	bucket := S3Bucket{Name: "b"}
+
+	f, err := NewS3File(bucket, "s", "t", WithDate(date), WithSuffix("json"), WithPartitionGranularity(PartitionHour))
+	assert.NoError(t, err)
+	assert.Equal(t, "s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/_data_timestamp_hour=14", f.Subfolder)
+	assert.Equal(t, "s3://b/"+f.Subfolder+"/s_t_2015-11-10T14:30:00Z.json", f.GetDataFilename())
+
+	// the hour is in UTC, and the option can come before the date
+	f, err = NewS3File(bucket, "s", "t", WithPartitionGranularity(PartitionHour),
+		WithDate(date.In(time.FixedZone("PST", -8*60*60))))
+	assert.NoError(t, err)
+	assert.Equal(t, "s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/_data_timestamp_hour=14", f.Subfolder)
+
+	f, err = NewS3File(bucket, "s", "t", WithDate(date), WithPartitionGranularity(PartitionDay))
+	assert.NoError(t, err)
+	assert.Equal(t, "s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10", f.Subfolder)
+
+	hourPath := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/_data_timestamp_hour=14/s_t_2015-11-10T14:30:00Z.json.gz"
+	f, err = CreateS3FileWithOptions(context.Background(), MockPathChecker{map[string]bool{hourPath: true}}, bucket, "s", "t", "", date,
+		WithPartitionGranularity(PartitionHour))
+	assert.NoError(t, err)
+	assert.Equal(t, hourPath, f.GetDataFilename())
+}
+
+func TestUppercaseSuffixes(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	folder := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/"
+	for _, test := range []struct {
+		suffix      string
+		format      FileFormat
+		compression Compression
+	}{
+		{"JSON.GZ", FormatJSON, CompressionGzip},
+		{".GZ", FormatCSV, CompressionGzip},
+		{"PARQUET", FormatParquet, CompressionNone},
+	} {
+		dataPath := folder + "s_t_2015-11-10T23:00:00Z." + normalizeSuffix(test.suffix)
+		pc := MockPathChecker{map[string]bool{dataPath: true}}
+
+		// off by default
+		_, err := CreateS3FileWithOptions(context.Background(), pc, bucket, "s", "t", "", expectedDate)
+		assert.Error(t, err)
+
+		f, err := CreateS3FileWithOptions(context.Background(), pc, bucket, "s", "t", "", expectedDate,
+			WithUppercaseSuffixes(), WithExtendedSuffixes())
+		assert.NoError(t, err)
+		assert.Equal(t, test.suffix, f.Suffix)
+		assert.Equal(t, dataPath, f.GetDataFilename())
+		assert.Equal(t, test.format, f.Format())
+		assert.Equal(t, test.compression, f.Compression())
+	}
+
+	// the lowercase suffix is still preferred
+	lower, upper := folder+"s_t_2015-11-10T23:00:00Z.json.gz", folder+"s_t_2015-11-10T23:00:00Z.JSON.GZ"
+	f, err := CreateS3FileWithOptions(context.Background(), MockPathChecker{map[string]bool{lower: true, upper: true}},
+		bucket, "s", "t", "", expectedDate, WithUppercaseSuffixes())
+	assert.NoError(t, err)
+	assert.Equal(t, lower, f.GetDataFilename())
+
+	_, err = CreateS3FileWithOptions(context.Background(), MockPathChecker{}, bucket, "s", "t", "", expectedDate, WithUppercaseSuffixes())
+	assert.Contains(t, err.Error(), folder+"s_t_2015-11-10T23:00:00Z.MANIFEST")
+
+	// an uppercase manifest is still a manifest
+	f.Suffix = "MANIFEST"
+	f.Bucket = testCopyBucket
+	sql, err := f.CopyCommand("", CopyOptions{Format: FormatJSON})
+	assert.NoError(t, err)
+	assert.Contains(t, sql, " MANIFEST ")
+}
+
+func TestConfigNamer(t *testing.T) {
+	namer := func(schema, table string, date time.Time) string {
+		return fmt.Sprintf("%s.%s.%s.config.yaml", schema, table, date.Format("2006-01-02"))
+	}
+	folder := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/"
+	dataPath := folder + "s_t_2015-11-10T23:00:00Z.json.gz"
+	confPath := folder + "s.t.2015-11-10.config.yaml"
+
+	f, err := NewS3File(S3Bucket{Name: "b"}, "s", "t", WithDate(expectedDate), WithConfigNamer(namer))
+	assert.NoError(t, err)
+	assert.Equal(t, confPath, f.GetConfigFilename())
+	assert.Equal(t, confPath, f.ConfFile)
+
+	pc := NewInMemoryPathChecker(dataPath, confPath)
+	found, err := CreateS3FileWithOptions(context.Background(), pc, S3Bucket{Name: "b"}, "s", "t", "", expectedDate,
+		WithConfigNamer(namer))
+	assert.NoError(t, err)
+	assert.Equal(t, dataPath, found.GetDataFilename())
+	assert.Equal(t, confPath, found.ConfFile)
+	// the default config names aren't looked for
+	assert.Equal(t, 0, pc.Checks(folder+"config_s_t_2015-11-10T23:00:00Z.yml"))
+	assert.Equal(t, 0, pc.Checks(folder+"config_s_t_2015-11-10T23:00:00Z.yaml"))
+
+	// a supplied config file still wins
+	found, err = CreateS3FileWithOptions(context.Background(), pc, S3Bucket{Name: "b"}, "s", "t", "s3://b/conf.yml",
+		expectedDate, WithConfigNamer(namer))
+	assert.NoError(t, err)
+	assert.Equal(t, "s3://b/conf.yml", found.ConfFile)
+}
+
+func TestRequireConfig(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	folder := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/"
+	dataPath := folder + "s_t_2015-11-10T23:00:00Z.json.gz"
+	confPath := folder + "config_s_t_2015-11-10T23:00:00Z.yml"
+
+	// the config file is missing
+	pc := NewInMemoryPathChecker(dataPath)
+	_, err := CreateS3FileWithOptions(context.Background(), pc, bucket, "s", "t", "", expectedDate, WithRequireConfig())
+	assert.True(t, errors.Is(err, ErrConfigNotFound))
+	assert.False(t, errors.Is(err, ErrFileNotFound))
+	assert.EqualError(t, err, "config file not found for s3 file "+dataPath+", tried: "+confPath+", "+
+		folder+"config_s_t_2015-11-10T23:00:00Z.yaml")
+
+	// without the option that's fine
+	f, err := CreateS3FileWithOptions(context.Background(), pc, bucket, "s", "t", "", expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, confPath, f.ConfFile)
+
+	pc.Add(confPath)
+	f, err = CreateS3FileWithOptions(context.Background(), pc, bucket, "s", "t", "", expectedDate, WithRequireConfig())
+	assert.NoError(t, err)
+	assert.Equal(t, confPath, f.ConfFile)
+
+	// a supplied config file has to exist too
+	_, err = CreateS3FileWithOptions(context.Background(), pc, bucket, "s", "t", "s3://b/missing.yml", expectedDate,
+		WithRequireConfig())
+	assert.True(t, errors.Is(err, ErrConfigNotFound))
+	assert.Contains(t, err.Error(), "s3://b/missing.yml")
+}
+
+func TestPartitionKeys(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	f, err := NewS3File(bucket, "s", "t", WithDate(expectedDate), WithSuffix("json"))
+	assert.NoError(t, err)
+	assert.Equal(t, "s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10", f.Subfolder)
+
+	f, err = NewS3File(bucket, "s", "t", WithDate(expectedDate), WithSuffix("json"), WithPartitionKeys(HivePartitionKeys))
+	assert.NoError(t, err)
+	assert.Equal(t, "s3://b/s/t/year=2015/month=11/day=10/s_t_2015-11-10T23:00:00Z.json", f.GetDataFilename())
+	assert.Equal(t, "s3://b/s/t/year=2015/month=11/day=10/config_s_t_2015-11-10T23:00:00Z.yml", f.ConfFile)
+
+	f, err = NewS3File(bucket, "s", "t", WithDate(expectedDate), WithPartitionKeys(HivePartitionKeys),
+		WithPartitionGranularity(PartitionHour))
+	assert.NoError(t, err)
+	assert.Equal(t, "s/t/year=2015/month=11/day=10/hour=23", f.Subfolder)
+
+	// empty names are the defaults
+	f, err = NewS3File(bucket, "s", "t", WithDate(expectedDate), WithPartitionKeys(PartitionKeys{Day: "dt"}))
+	assert.NoError(t, err)
+	assert.Equal(t, "s/t/_data_timestamp_year=2015/_data_timestamp_month=11/dt=10", f.Subfolder)
+
+	// lookups use them
+	dataPath := "s3://b/s/t/year=2015/month=11/day=10/s_t_2015-11-10T23:00:00Z.json.gz"
+	found, err := CreateS3FileWithOptions(context.Background(), NewInMemoryPathChecker(dataPath), bucket, "s", "t", "",
+		expectedDate, WithPartitionKeys(HivePartitionKeys))
+	assert.NoError(t, err)
+	assert.Equal(t, dataPath, found.GetDataFilename())
+
+	// and they survive JSON
+	data, err := json.Marshal(found)
+	assert.NoError(t, err)
+	var parsed S3File
+	assert.NoError(t, json.Unmarshal(data, &parsed))
+	assert.Equal(t, *found, parsed)
+}
+
+func TestWithSeparator(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	f, err := NewS3File(bucket, "s", "my_table", WithDate(expectedDate), WithSuffix("json.gz"), WithSeparator("."))
+	assert.NoError(t, err)
+	dataPath := "s3://b/s/my_table/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s.my_table.2015-11-10T23:00:00Z.json.gz"
+	assert.Equal(t, dataPath, f.GetDataFilename())
+	assert.Equal(t, "s3://b/s/my_table/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/config.s.my_table.2015-11-10T23:00:00Z.yml",
+		f.ConfFile)
+
+	parsed, err := ParseDataFilenameWithSeparator(dataPath, ".")
+	assert.NoError(t, err)
+	assert.True(t, f.Equal(parsed))
+	assert.Equal(t, dataPath, parsed.GetDataFilename())
+	assert.Equal(t, f.ConfFile, parsed.ConfFile)
+
+	// the default separator doesn't match dotted names, and vice versa
+	_, err = ParseDataFilename(dataPath)
+	assert.Error(t, err)
+	_, err = ParseDataFilenameWithSeparator(buildS3File(bucket, "s", "my_table", "", expectedDate, "json.gz").GetDataFilename(), ".")
+	assert.Error(t, err)
+
+	// CreateS3File looks for dotted names
+	pc := MockPathChecker{map[string]bool{dataPath: true}}
+	found, err := CreateS3FileWithOptions(context.Background(), pc, bucket, "s", "my_table", "", expectedDate, WithSeparator("."))
+	assert.NoError(t, err)
+	assert.Equal(t, dataPath, found.GetDataFilename())
+
+	// and it survives a trip through JSON
+	data, err := json.Marshal(found)
+	assert.NoError(t, err)
+	var unmarshalled S3File
+	assert.NoError(t, json.Unmarshal(data, &unmarshalled))
+	assert.Equal(t, dataPath, unmarshalled.GetDataFilename())
+
+	for _, sep := range []string{"", "/", "x", "-1"} {
+		_, err = NewS3File(bucket, "s", "t", WithSeparator(sep))
+		if sep == "" {
+			assert.NoError(t, err, "empty means the default")
+			continue
+		}
+		assert.Error(t, err, sep)
+		_, err = ParseDataFilenameWithSeparator(dataPath, sep)
+		assert.Error(t, err, sep)
+		_, err = CreateS3FileWithOptions(context.Background(), pc, bucket, "s", "t", "", expectedDate, WithSeparator(sep))
+		assert.Error(t, err, sep)
+	}
+}
+
+func TestWithoutSchemaPrefix(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	folder := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/"
+	f, err := NewS3File(bucket, "s", "t", WithDate(expectedDate), WithSuffix("json.gz"), WithoutSchemaPrefix())
+	assert.NoError(t, err)
+	assert.Equal(t, folder+"t_2015-11-10T23:00:00Z.json.gz", f.GetDataFilename())
+	assert.Equal(t, folder+"config_t_2015-11-10T23:00:00Z.yml", f.ConfFile)
+
+	// only the table-only file exists, so it's only found in this mode
+	pc := MockPathChecker{map[string]bool{folder + "t_2015-11-10T23:00:00Z.json.gz": true}}
+	_, err = CreateS3File(pc, bucket, "s", "t", "", expectedDate)
+	assert.Error(t, err)
+	found, err := CreateS3FileWithOptions(context.Background(), pc, bucket, "s", "t", "", expectedDate, WithoutSchemaPrefix())
+	assert.NoError(t, err)
+	assert.Equal(t, f.GetDataFilename(), found.GetDataFilename())
+	assert.Equal(t, "json.gz", found.Suffix)
+
+	parsed, err := ParseDataFilename(f.GetDataFilename())
+	assert.NoError(t, err)
+	assert.Equal(t, f, parsed)
+	// the full name still parses to a normal file
+	parsed, err = ParseDataFilename(folder + "s_t_2015-11-10T23:00:00Z.json.gz")
+	assert.NoError(t, err)
+	assert.Equal(t, buildS3File(bucket, "s", "t", "", expectedDate, "json.gz"), parsed)
+	_, err = ParseDataFilename(folder + "other_2015-11-10T23:00:00Z.json.gz")
+	assert.EqualError(t, err, "data filename other_2015-11-10T23:00:00Z.json.gz does not start with s_t_ or t_")
+
+	data, err := json.Marshal(f)
+	assert.NoError(t, err)
+	var unmarshalled S3File
+	assert.NoError(t, json.Unmarshal(data, &unmarshalled))
+	assert.Equal(t, f.GetDataFilename(), unmarshalled.GetDataFilename())
+	assert.Equal(t, f.ConfFile, unmarshalled.ConfFile)
+}
+
+func TestWithAmbiguityCheck(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	folder := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/"
+	manifestPath := folder + "s_t_2015-11-10T23:00:00Z.manifest"
+	jsonGzPath := folder + "s_t_2015-11-10T23:00:00Z.json.gz"
+	pc := MockPathChecker{map[string]bool{manifestPath: true, jsonGzPath: true}}
+
+	// without the check the manifest wins
+	f, err := CreateS3FileWithOptions(context.Background(), pc, bucket, "s", "t", "", expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, manifestPath, f.GetDataFilename())
+
+	_, err = CreateS3FileWithOptions(context.Background(), pc, bucket, "s", "t", "", expectedDate, WithAmbiguityCheck())
+	var ambiguous *AmbiguousFileError
+	assert.True(t, errors.As(err, &ambiguous))
+	assert.Equal(t, manifestPath, ambiguous.Manifest)
+	assert.Equal(t, []string{jsonGzPath}, ambiguous.DataFiles)
+	assert.EqualError(t, err, "both manifest "+manifestPath+" and data file "+jsonGzPath+" exist, only one of them should be loaded")
+
+	// just one of them is fine
+	pc = MockPathChecker{map[string]bool{manifestPath: true}}
+	f, err = CreateS3FileWithOptions(context.Background(), pc, bucket, "s", "t", "", expectedDate, WithAmbiguityCheck())
+	assert.NoError(t, err)
+	assert.Equal(t, manifestPath, f.GetDataFilename())
+	pc = MockPathChecker{map[string]bool{jsonGzPath: true}}
+	f, err = CreateS3FileWithOptions(context.Background(), pc, bucket, "s", "t", "", expectedDate, WithAmbiguityCheck())
+	assert.NoError(t, err)
+	assert.Equal(t, jsonGzPath, f.GetDataFilename())
+}
+
+func TestWithExplicitSubfolder(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	f, err := NewS3File(bucket, "s", "t", WithDate(expectedDate), WithSuffix("json"),
+		WithExplicitSubfolder("exports/custom"), WithPartitionGranularity(PartitionHour))
+	assert.NoError(t, err)
+	assert.Equal(t, "exports/custom", f.Subfolder)
+	assert.Equal(t, "s3://b/exports/custom/s_t_2015-11-10T23:00:00Z.json", f.GetDataFilename())
+	assert.Equal(t, "s3://b/exports/custom/config_s_t_2015-11-10T23:00:00Z.yml", f.ConfFile)
+	assert.NotContains(t, f.GetDataFilename(), "_data_timestamp")
+
+	// empty is the bucket root, not the default partitions
+	f, err = NewS3File(bucket, "s", "t", WithDate(expectedDate), WithSuffix("json"), WithExplicitSubfolder(""))
+	assert.NoError(t, err)
+	assert.Equal(t, "s3://b/s_t_2015-11-10T23:00:00Z.json", f.GetDataFilename())
+
+	pc := MockPathChecker{map[string]bool{"s3://b/s_t_2015-11-10T23:00:00Z.json": true}}
+	found, err := CreateS3FileWithOptions(context.Background(), pc, bucket, "s", "t", "", expectedDate,
+		WithExplicitSubfolder(""))
+	assert.NoError(t, err)
+	assert.Equal(t, f.GetDataFilename(), found.GetDataFilename())
+	assert.Equal(t, "s3://b/config_s_t_2015-11-10T23:00:00Z.yml", found.ConfFile)
+
+	data, err := json.Marshal(found)
+	assert.NoError(t, err)
+	var unmarshalled S3File
+	assert.NoError(t, json.Unmarshal(data, &unmarshalled))
+	assert.Equal(t, found.GetDataFilename(), unmarshalled.GetDataFilename())
+}
@@ -0,0 +1,182 @@
+package s3filepath
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PartitionPath returns the default Subfolder CreateS3File looks for the table's data for the date
+// in, e.g. schema/table/_data_timestamp_year=2015/_data_timestamp_month=01/_data_timestamp_day=02.
+// It doesn't include the bucket or its Prefix. The date is converted to UTC first.
+func PartitionPath(schema, table string, date time.Time) string {
+	return partitionPath(schema, table, date.UTC(), PartitionKeys{}, PartitionDay)
+}
+
+// partitionPath is PartitionPath with the partition folder names and granularity from the options
+func partitionPath(schema, table string, date time.Time, keys PartitionKeys, granularity PartitionGranularity) string {
+	keys = keys.withDefaults()
+	partition := fmt.Sprintf("%s/%s/%s=%02d/%s=%02d/%s=%02d", schema, table,
+		keys.Year, date.Year(), keys.Month, int(date.Month()), keys.Day, date.Day())
+	if granularity == PartitionHour {
+		partition += fmt.Sprintf("/%s=%02d", keys.Hour, date.Hour())
+	}
+	return partition
+}
+
+// ListPartitionsOlderThan lists the table's day partition folders and returns the ones whose date
+// is before olderThan, as full s3 paths with a trailing slash, oldest first. Anything under
+// schema/table/ that isn't in a _data_timestamp_year=/month=/day= folder is ignored.
+// WithPartitionKeys changes the folder names it looks for, e.g. to HivePartitionKeys.
+func ListPartitionsOlderThan(lister Lister, bucket S3Bucket, schema, table string, olderThan time.Time,
+	opts ...S3FileOption) ([]string, error) {
+	if err := validateNames(schema, table); err != nil {
+		return nil, err
+	}
+	keys := newS3File(bucket, schema, table, opts...).partitionKeys.withDefaults()
+	tablePrefix := s3Path(bucket, schema, table) + "/"
+	paths, err := lister.List(tablePrefix)
+	if err != nil {
+		return nil, fmt.Errorf("error listing %s: %w", tablePrefix, err)
+	}
+
+	dates := map[string]time.Time{}
+	for _, p := range paths {
+		folders := strings.Split(strings.TrimPrefix(p, tablePrefix), "/")
+		if len(folders) < 4 {
+			// at least year, month and day folders and a file in them
+			continue
+		}
+		date, ok := parsePartition(keys, folders[0], folders[1], folders[2])
+		if !ok {
+			continue
+		}
+		dates[tablePrefix+path.Join(folders[:3]...)+"/"] = date
+	}
+
+	var old []string
+	for prefix, date := range dates {
+		if date.Before(olderThan) {
+			old = append(old, prefix)
+		}
+	}
+	// the folder names are zero padded so they sort by date
+	sort.Strings(old)
+	return old, nil
+}
+
+// parsePartition parses the date out of the year, month and day partition folder names,
+// e.g. _data_timestamp_year=2015 with the default keys
+func parsePartition(keys PartitionKeys, year, month, day string) (time.Time, bool) {
+	y, ok := parsePartitionFolder(year, keys.Year, 4)
+	if !ok {
+		return time.Time{}, false
+	}
+	m, ok := parsePartitionFolder(month, keys.Month, 2)
+	if !ok {
+		return time.Time{}, false
+	}
+	d, ok := parsePartitionFolder(day, keys.Day, 2)
+	if !ok {
+		return time.Time{}, false
+	}
+	date := time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC)
+	// reject dates like month=13 that time.Date would normalize
+	if date.Year() != y || int(date.Month()) != m || date.Day() != d {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// parsePartitionFolder parses the number out of a key=value folder name, where the value
+// has exactly digits digits
+func parsePartitionFolder(folder, key string, digits int) (int, bool) {
+	value := strings.TrimPrefix(folder, key+"=")
+	if value == folder || len(value) != digits {
+		return 0, false
+	}
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+	n, err := strconv.Atoi(value)
+	return n, err == nil
+}
+
+// TableRef is a table found in a bucket by DiscoverTables
+type TableRef struct {
+	Schema string
+	Table  string
+	// Dates are the days the table has partition folders with files in them for, in ascending order
+	Dates []time.Time
+}
+
+// DiscoverTables lists everything in the bucket (under its Prefix) and returns every table with
+// data in the default schema/table/_data_timestamp_year=/month=/day= layout, sorted by schema and
+// then table. Keys that aren't in that layout, or whose schema or table isn't a valid name, are
+// skipped. This lists the whole bucket, so it can be slow for big ones.
+// WithPartitionKeys changes the folder names it looks for, e.g. to HivePartitionKeys.
+func DiscoverTables(lister Lister, bucket S3Bucket, opts ...S3FileOption) ([]TableRef, error) {
+	keys := newS3File(bucket, "", "", opts...).partitionKeys.withDefaults()
+	root := strings.TrimSuffix(s3Path(bucket), "/") + "/"
+	paths, err := lister.List(root)
+	if err != nil {
+		return nil, fmt.Errorf("error listing %s: %w", root, err)
+	}
+
+	tables := map[[2]string]map[time.Time]bool{}
+	for _, p := range paths {
+		folders := strings.Split(strings.TrimPrefix(p, root), "/")
+		if len(folders) < 6 || validateNames(folders[0], folders[1]) != nil {
+			// at least schema, table, year, month and day folders and a file in them
+			continue
+		}
+		date, ok := parsePartition(keys, folders[2], folders[3], folders[4])
+		if !ok {
+			continue
+		}
+		key := [2]string{folders[0], folders[1]}
+		if tables[key] == nil {
+			tables[key] = map[time.Time]bool{}
+		}
+		tables[key][date] = true
+	}
+
+	refs := make([]TableRef, 0, len(tables))
+	for key, dates := range tables {
+		ref := TableRef{Schema: key[0], Table: key[1]}
+		for date := range dates {
+			ref.Dates = append(ref.Dates, date)
+		}
+		sort.Slice(ref.Dates, func(i, j int) bool { return ref.Dates[i].Before(ref.Dates[j]) })
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Schema != refs[j].Schema {
+			return refs[i].Schema < refs[j].Schema
+		}
+		return refs[i].Table < refs[j].Table
+	})
+	return refs, nil
+}
+
+// DeletePrefixes deletes every file under each of the prefixes, e.g. the partitions returned by
+// ListPartitionsOlderThan. Files that are already gone aren't an error.
+func DeletePrefixes(lister Lister, d Deleter, prefixes []string) error {
+	for _, prefix := range prefixes {
+		paths, err := lister.List(prefix)
+		if err != nil {
+			return fmt.Errorf("error listing %s: %w", prefix, err)
+		}
+		for _, p := range paths {
+			if err := deleteIfExists(d, p); err != nil {
+				return fmt.Errorf("error deleting %s: %w", p, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package s3filepath
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListPartitionsOlderThan(t *testing.T) {
+	day := func(d string) string {
+		return "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=" + d + "/"
+	}
+	lister := MockLister{Paths: []string{
+		day("08") + "s_t_2015-11-08T23:00:00Z.json",
+		day("08") + "config_s_t_2015-11-08T23:00:00Z.yml",
+		day("09") + "s_t_2015-11-09T23:00:00Z.json",
+		day("10") + "s_t_2015-11-10T23:00:00Z.json",
+		day("11") + "s_t_2015-11-11T23:00:00Z.json",
+		"s3://b/s/t/_data_timestamp_year=2014/_data_timestamp_month=12/_data_timestamp_day=31/_data_timestamp_hour=01/s_t_2014-12-31T01:00:00Z.json",
+		// not partitions
+		"s3://b/s/t/README",
+		"s3://b/s/t/_data_timestamp_year=2015/loose.json",
+		"s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=13/_data_timestamp_day=01/x.json",
+		"s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=1/_data_timestamp_day=01/x.json",
+		"s3://b/s/t/backup/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=01/x.json",
+		// other tables
+		"s3://b/s/t2/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=01/x.json",
+	}}
+
+	old, err := ListPartitionsOlderThan(lister, S3Bucket{Name: "b"}, "s", "t", time.Date(2015, 11, 10, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"s3://b/s/t/_data_timestamp_year=2014/_data_timestamp_month=12/_data_timestamp_day=31/",
+		day("08"),
+		day("09"),
+	}, old)
+
+	old, err = ListPartitionsOlderThan(lister, S3Bucket{Name: "b"}, "s", "t", time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Empty(t, old)
+
+	_, err = ListPartitionsOlderThan(MockLister{Err: errors.New("denied")}, S3Bucket{Name: "b"}, "s", "t", expectedDate)
+	assert.Error(t, err)
+
+	hive := MockLister{Paths: []string{
+		"s3://b/s/t/year=2015/month=11/day=08/s_t_2015-11-08T23:00:00Z.json",
+		"s3://b/s/t/year=2015/month=11/day=11/s_t_2015-11-11T23:00:00Z.json",
+		"s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=01/x.json",
+	}}
+	old, err = ListPartitionsOlderThan(hive, S3Bucket{Name: "b"}, "s", "t", time.Date(2015, 11, 10, 0, 0, 0, 0, time.UTC),
+		WithPartitionKeys(HivePartitionKeys))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"s3://b/s/t/year=2015/month=11/day=08/"}, old)
+}
+
+func TestDeletePrefixes(t *testing.T) {
+	prefix := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=08/"
+	data, conf := prefix+"s_t_2015-11-08T23:00:00Z.json", prefix+"config_s_t_2015-11-08T23:00:00Z.yml"
+	recent := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z.json"
+	lister := MockLister{Paths: []string{data, conf, recent}}
+	// the config is already gone
+	d := &MockDeleter{ExistingPaths: map[string]bool{data: true, recent: true}}
+
+	assert.NoError(t, DeletePrefixes(lister, d, []string{prefix}))
+	assert.Equal(t, []string{data}, d.Deleted)
+	assert.True(t, d.ExistingPaths[recent])
+
+	d.Err = errors.New("denied")
+	assert.Error(t, DeletePrefixes(lister, d, []string{prefix}))
+	assert.Error(t, DeletePrefixes(MockLister{Err: errors.New("denied")}, d, []string{prefix}))
+}
+
+func TestPartitionPath(t *testing.T) {
+	date := time.Date(2015, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.Equal(t, "s/t/_data_timestamp_year=2015/_data_timestamp_month=01/_data_timestamp_day=02", PartitionPath("s", "t", date))
+	// converted to UTC, where it's already the next day
+	assert.Equal(t, "s/t/_data_timestamp_year=2015/_data_timestamp_month=01/_data_timestamp_day=03",
+		PartitionPath("s", "t", time.Date(2015, 1, 2, 20, 0, 0, 0, time.FixedZone("PST", -8*3600))))
+
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", date, "json")
+	assert.Equal(t, PartitionPath("s", "t", date), f.Subfolder)
+}
+
+func TestDiscoverTables(t *testing.T) {
+	day := func(schema, table string, d int) string {
+		return fmt.Sprintf("s3://b/%s/%s/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=%02d/%s_%s.json",
+			schema, table, d, schema, table)
+	}
+	lister := MockLister{Paths: []string{
+		day("s", "t", 10),
+		day("s", "t", 9),
+		// two files on the same day
+		strings.Replace(day("s", "t", 10), ".json", ".manifest", 1),
+		day("s", "a", 1),
+		day("other", "events", 2),
+		// not in the layout
+		"s3://b/s/t/config_s_t.yml",
+		"s3://b/s/u/_data_timestamp_year=2015/_data_timestamp_month=13/_data_timestamp_day=01/s_u.json",
+		"s3://b/s/v/year=2015/month=11/day=01/s_v.json",
+		"s3://b/bad-schema/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=01/x.json",
+		"s3://b/README",
+	}}
+	date := func(d int) time.Time { return time.Date(2015, 11, d, 0, 0, 0, 0, time.UTC) }
+
+	refs, err := DiscoverTables(lister, S3Bucket{Name: "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, []TableRef{
+		{Schema: "other", Table: "events", Dates: []time.Time{date(2)}},
+		{Schema: "s", Table: "a", Dates: []time.Time{date(1)}},
+		{Schema: "s", Table: "t", Dates: []time.Time{date(9), date(10)}},
+	}, refs)
+
+	// only what's under the bucket prefix
+	lister.Paths = append(lister.Paths, "s3://b/prod/s/p/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=03/s_p.json")
+	refs, err = DiscoverTables(lister, S3Bucket{Name: "b", Prefix: "prod"})
+	assert.NoError(t, err)
+	assert.Equal(t, []TableRef{{Schema: "s", Table: "p", Dates: []time.Time{date(3)}}}, refs)
+
+	// the s/v folders are the only ones in the hive layout
+	refs, err = DiscoverTables(lister, S3Bucket{Name: "b"}, WithPartitionKeys(HivePartitionKeys))
+	assert.NoError(t, err)
+	assert.Equal(t, []TableRef{{Schema: "s", Table: "v", Dates: []time.Time{date(1)}}}, refs)
+
+	refs, err = DiscoverTables(MockLister{}, S3Bucket{Name: "b"})
+	assert.NoError(t, err)
+	assert.Empty(t, refs)
+	_, err = DiscoverTables(MockLister{Err: errors.New("access denied")}, S3Bucket{Name: "b"})
+	assert.EqualError(t, err, "error listing s3://b/: access denied")
+}
@@ -0,0 +1,79 @@
+package s3filepath
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// partRegex matches the name of a part without its suffix, e.g. part-00000 or Spark's
+// part-00000-<uuid>, capturing the part number
+var partRegex = regexp.MustCompile(`^part-(\d+)(?:-[^.]+)?$`)
+
+// FindParts lists the subfolder the table's data file would be in for the date, and returns an
+// S3File for each part of the data in it with the suffix, e.g. part-00000.json.gz, part-00001.json.gz,
+// ordered by part number. It's an error if there are no parts, or if the usual single data file
+// also exists, since it's then unclear which of them has the data.
+func FindParts(lister Lister, bucket S3Bucket, schema, table string, date time.Time, suffix string) ([]*S3File, error) {
+	if err := validateNames(schema, table); err != nil {
+		return nil, err
+	}
+	f := buildS3File(bucket, schema, table, "", date, suffix)
+	paths, err := lister.List(f.folder())
+	if err != nil {
+		return nil, fmt.Errorf("error listing %s: %w", f.folder(), err)
+	}
+
+	type part struct {
+		number int
+		name   string
+	}
+	var parts []part
+	single := false
+	for _, p := range paths {
+		if p == f.GetDataFilename() {
+			single = true
+			continue
+		}
+		// only files straight in the folder, with exactly the suffix
+		name := strings.TrimPrefix(p, f.folder())
+		if strings.Contains(name, "/") || fileSuffix(name) != normalizeSuffix(suffix) {
+			continue
+		}
+		name = strings.TrimSuffix(name, formatSuffix(suffix))
+		matches := partRegex.FindStringSubmatch(name)
+		if matches == nil {
+			continue
+		}
+		number, err := strconv.Atoi(matches[1])
+		if err != nil {
+			continue
+		}
+		parts = append(parts, part{number, name})
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("no %q parts found under %s", suffix, f.folder())
+	}
+	if single {
+		return nil, fmt.Errorf("both %s and parts like %s%s exist under %s, so it's ambiguous which has the data",
+			path.Base(f.GetDataFilename()), parts[0].name, formatSuffix(suffix), f.folder())
+	}
+
+	sort.Slice(parts, func(i, j int) bool {
+		if parts[i].number != parts[j].number {
+			return parts[i].number < parts[j].number
+		}
+		return parts[i].name < parts[j].name
+	})
+	files := make([]*S3File, 0, len(parts))
+	for _, p := range parts {
+		partFile := *f
+		partFile.Part = p.name
+		files = append(files, &partFile)
+	}
+	return files, nil
+}
@@ -0,0 +1,63 @@
+package s3filepath
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindParts(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	folder := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/"
+	lister := MockLister{Paths: []string{
+		folder + "part-00010.json.gz",
+		folder + "part-00002.json.gz",
+		folder + "part-00001-5b1f4c1e.json.gz",
+		folder + "part-00000.json", // a different suffix
+		folder + "config_s_t_2015-11-10T23:00:00Z.yml",
+		folder + "other/part-00003.json.gz",
+		folder + "_SUCCESS",
+	}}
+
+	parts, err := FindParts(lister, bucket, "s", "t", expectedDate, "json.gz")
+	assert.NoError(t, err)
+	var paths []string
+	for _, p := range parts {
+		paths = append(paths, p.GetDataFilename())
+		assert.Equal(t, "json.gz", p.Suffix)
+		assert.Equal(t, FormatJSON, p.Format())
+		assert.Equal(t, CompressionGzip, p.Compression())
+	}
+	assert.Equal(t, []string{
+		folder + "part-00001-5b1f4c1e.json.gz",
+		folder + "part-00002.json.gz",
+		folder + "part-00010.json.gz",
+	}, paths)
+	assert.Equal(t, "part-00002", parts[1].Part)
+	assert.False(t, parts[0].Equal(parts[1]))
+
+	// csv parts have no suffix at all
+	lister = MockLister{Paths: []string{folder + "part-00001", folder + "part-00000", folder + "part-00000.gz"}}
+	parts, err = FindParts(lister, bucket, "s", "t", expectedDate, "")
+	assert.NoError(t, err)
+	if assert.Len(t, parts, 2) {
+		assert.Equal(t, folder+"part-00000", parts[0].GetDataFilename())
+	}
+}
+
+func TestFindPartsErrors(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	folder := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/"
+
+	_, err := FindParts(MockLister{Paths: []string{folder + "part-00000.json"}}, bucket, "s", "t", expectedDate, "json.gz")
+	assert.EqualError(t, err, `no "json.gz" parts found under `+folder)
+
+	lister := MockLister{Paths: []string{folder + "part-00000.json.gz", folder + "s_t_2015-11-10T23:00:00Z.json.gz"}}
+	_, err = FindParts(lister, bucket, "s", "t", expectedDate, "json.gz")
+	assert.EqualError(t, err, "both s_t_2015-11-10T23:00:00Z.json.gz and parts like part-00000.json.gz exist under "+folder+
+		", so it's ambiguous which has the data")
+
+	_, err = FindParts(MockLister{Err: errors.New("access denied")}, bucket, "s", "t", expectedDate, "json.gz")
+	assert.EqualError(t, err, "error listing "+folder+": access denied")
+}
@@ -0,0 +1,439 @@
+package s3filepath
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// LocalPathChecker looks for files in a local directory tree that mirrors the bucket layout,
+// which is handy for tests and for developing offline.
+// s3://bucket/key is looked up at Root/bucket/key.
+type LocalPathChecker struct {
+	Root string
+}
+
+// FileExists checks whether the local file for the s3 path exists
+func (lp LocalPathChecker) FileExists(path string) bool {
+	info, err := os.Stat(lp.localPath(path))
+	return err == nil && !info.IsDir()
+}
+
+// localPath maps an s3 or gs path onto the local filesystem
+func (lp LocalPathChecker) localPath(path string) string {
+	_, key, _ := splitScheme(path)
+	return filepath.Join(lp.Root, filepath.FromSlash(key))
+}
+
+// InMemoryPathChecker is a PathChecker for tests: the paths that exist are set up front, and
+// every check is recorded so tests can assert which paths were looked for, in what order.
+// Checks can be made to fail with Fail, and it's also a Lister of the paths that exist.
+// It is safe for concurrent use.
+type InMemoryPathChecker struct {
+	mu       sync.Mutex
+	existing map[string]bool
+	checked  []string
+	failures []error
+	listed   []string
+}
+
+// NewInMemoryPathChecker returns an InMemoryPathChecker where the given paths exist
+func NewInMemoryPathChecker(existing ...string) *InMemoryPathChecker {
+	pc := &InMemoryPathChecker{existing: map[string]bool{}}
+	pc.Add(existing...)
+	return pc
+}
+
+// Add makes the paths exist
+func (pc *InMemoryPathChecker) Add(paths ...string) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	for _, p := range paths {
+		pc.existing[p] = true
+	}
+}
+
+// Remove makes the paths not exist
+func (pc *InMemoryPathChecker) Remove(paths ...string) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	for _, p := range paths {
+		delete(pc.existing, p)
+	}
+}
+
+// Fail makes the next checks return the errors in turn, whatever the path, before it goes back
+// to answering from the paths that exist. A nil error answers its check as usual.
+func (pc *InMemoryPathChecker) Fail(errs ...error) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.failures = append(pc.failures, errs...)
+}
+
+// FileExists is FileExistsErr, with a failed check treated as a miss
+func (pc *InMemoryPathChecker) FileExists(path string) bool {
+	exists, _ := pc.FileExistsErr(path)
+	return exists
+}
+
+// FileExistsErr records the check and returns the next error passed to Fail,
+// or else whether the path was added
+func (pc *InMemoryPathChecker) FileExistsErr(path string) (bool, error) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.checked = append(pc.checked, path)
+	if len(pc.failures) > 0 {
+		err := pc.failures[0]
+		pc.failures = pc.failures[1:]
+		if err != nil {
+			return false, err
+		}
+	}
+	return pc.existing[path], nil
+}
+
+// List records the prefix and returns the paths that exist under it, sorted like S3 lists them
+func (pc *InMemoryPathChecker) List(prefix string) ([]string, error) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.listed = append(pc.listed, prefix)
+	var found []string
+	for p := range pc.existing {
+		if strings.HasPrefix(p, prefix) {
+			found = append(found, p)
+		}
+	}
+	sort.Strings(found)
+	return found, nil
+}
+
+// Listed returns every prefix listed so far, in order
+func (pc *InMemoryPathChecker) Listed() []string {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	return append([]string(nil), pc.listed...)
+}
+
+// Checked returns every path checked so far, in order, including repeats
+func (pc *InMemoryPathChecker) Checked() []string {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	return append([]string(nil), pc.checked...)
+}
+
+// Checks returns how many times the path has been checked
+func (pc *InMemoryPathChecker) Checks(path string) int {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	n := 0
+	for _, p := range pc.checked {
+		if p == path {
+			n++
+		}
+	}
+	return n
+}
+
+// ResetChecks forgets the checks and listings made so far, keeping the existing paths
+func (pc *InMemoryPathChecker) ResetChecks() {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.checked = nil
+	pc.listed = nil
+}
+
+// CachingPathChecker wraps a PathChecker and remembers its answers for a while,
+// so repeated checks for the same path don't hit S3 again.
+// Both hits and misses are cached, but failed lookups never are. It passes FileExistsErr and
+// Stat through to the wrapped PathChecker when it has them, so CreateS3File still sees lookup
+// errors and object metadata through the cache. It is safe for concurrent use.
+type CachingPathChecker struct {
+	pc  PathChecker
+	ttl time.Duration
+	now func() time.Time // for testing
+
+	mu    sync.Mutex
+	cache map[string]cachedCheck
+}
+
+type cachedCheck struct {
+	exists bool
+	// info is only set if the check was a Stat
+	info    S3ObjectInfo
+	statted bool
+	expires time.Time
+}
+
+// NewCachingPathChecker returns a CachingPathChecker that caches results from pc for ttl.
+// A ttl of zero or less caches results until they are invalidated.
+func NewCachingPathChecker(pc PathChecker, ttl time.Duration) *CachingPathChecker {
+	return &CachingPathChecker{
+		pc:    pc,
+		ttl:   ttl,
+		now:   time.Now,
+		cache: map[string]cachedCheck{},
+	}
+}
+
+// FileExists returns the cached result for the path, or asks the wrapped PathChecker.
+// A failed lookup counts as the file not existing, and isn't cached.
+func (cp *CachingPathChecker) FileExists(path string) bool {
+	exists, _ := cp.FileExistsErr(path)
+	return exists
+}
+
+// FileExistsErr returns the cached result for the path, or asks the wrapped PathChecker,
+// returning its error if it's an ErrorPathChecker and the lookup failed
+func (cp *CachingPathChecker) FileExistsErr(path string) (bool, error) {
+	if cached, ok := cp.cached(path); ok {
+		return cached.exists, nil
+	}
+
+	// don't hold the lock while talking to S3
+	var exists bool
+	if epc, ok := cp.pc.(ErrorPathChecker); ok {
+		var err error
+		if exists, err = epc.FileExistsErr(path); err != nil {
+			return false, err
+		}
+	} else {
+		exists = cp.pc.FileExists(path)
+	}
+	cp.store(path, cachedCheck{exists: exists})
+	return exists, nil
+}
+
+// Stat returns the cached metadata for the path, or asks the wrapped PathChecker. If that isn't
+// a Statter, files that exist have no metadata. A missing file is a not found error, like S3's.
+func (cp *CachingPathChecker) Stat(path string) (S3ObjectInfo, error) {
+	cached, ok := cp.cached(path)
+	statter, canStat := cp.pc.(Statter)
+	if ok && (!cached.exists || cached.statted || !canStat) {
+		if !cached.exists {
+			return S3ObjectInfo{}, notExistError(path)
+		}
+		return cached.info, nil
+	}
+	if !canStat {
+		exists, err := cp.FileExistsErr(path)
+		if err != nil {
+			return S3ObjectInfo{}, err
+		}
+		if !exists {
+			return S3ObjectInfo{}, notExistError(path)
+		}
+		return S3ObjectInfo{}, nil
+	}
+
+	info, err := statter.Stat(path)
+	if err != nil {
+		if isNotFound(err) {
+			cp.store(path, cachedCheck{})
+		}
+		return S3ObjectInfo{}, err
+	}
+	cp.store(path, cachedCheck{exists: true, info: info, statted: true})
+	return info, nil
+}
+
+// cached returns the unexpired cached check for the path, if there is one
+func (cp *CachingPathChecker) cached(path string) (cachedCheck, bool) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cached, ok := cp.cache[path]
+	if !ok || (cp.ttl > 0 && !cp.now().Before(cached.expires)) {
+		return cachedCheck{}, false
+	}
+	return cached, true
+}
+
+func (cp *CachingPathChecker) store(path string, check cachedCheck) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	check.expires = cp.now().Add(cp.ttl)
+	cp.cache[path] = check
+}
+
+// notExistError is the error for a missing file from PathCheckers that aren't backed by S3
+func notExistError(path string) error {
+	return &os.PathError{Op: "stat", Path: path, Err: os.ErrNotExist}
+}
+
+// Invalidate forgets the cached result for the path, e.g. when we know a file just landed
+func (cp *CachingPathChecker) Invalidate(path string) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	delete(cp.cache, path)
+}
+
+// RetryingPathChecker wraps an ErrorPathChecker and retries lookups that failed with
+// an error, waiting between attempts as its BackoffStrategy says. Missing files aren't retried.
+type RetryingPathChecker struct {
+	pc          ErrorPathChecker
+	maxAttempts int
+	backoff     BackoffStrategy
+	sleep       func(time.Duration) // for testing
+}
+
+// NewRetryingPathChecker returns a RetryingPathChecker that tries each lookup up to maxAttempts
+// times, waiting backoff after the first failure and doubling the wait after each one after that.
+func NewRetryingPathChecker(pc ErrorPathChecker, maxAttempts int, backoff time.Duration) *RetryingPathChecker {
+	return NewRetryingPathCheckerWithBackoff(pc, maxAttempts, ExponentialBackoff{Base: backoff})
+}
+
+// NewRetryingPathCheckerWithBackoff returns a RetryingPathChecker that tries each lookup up to
+// maxAttempts times, waiting between attempts as backoff says
+func NewRetryingPathCheckerWithBackoff(pc ErrorPathChecker, maxAttempts int, backoff BackoffStrategy) *RetryingPathChecker {
+	return &RetryingPathChecker{
+		pc:          pc,
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		sleep:       time.Sleep,
+	}
+}
+
+// FileExists returns whether the file exists, treating a lookup that kept failing as missing
+func (rp *RetryingPathChecker) FileExists(path string) bool {
+	exists, _ := rp.FileExistsErr(path)
+	return exists
+}
+
+// FileExistsErr returns whether the file exists, or the last error if every attempt failed
+func (rp *RetryingPathChecker) FileExistsErr(path string) (bool, error) {
+	for attempt := 1; ; attempt++ {
+		exists, err := rp.pc.FileExistsErr(path)
+		if err == nil || attempt >= rp.maxAttempts || !isRetryable(err) {
+			return exists, err
+		}
+		rp.sleep(rp.backoff.NextDelay(attempt))
+	}
+}
+
+// isRetryable returns whether a failed lookup is worth trying again. Client errors like
+// access denied won't fix themselves, but throttling, server errors and timeouts might.
+func isRetryable(err error) bool {
+	var reqErr awserr.RequestFailure
+	if errors.As(err, &reqErr) {
+		code := reqErr.StatusCode()
+		if code >= 400 && code < 500 && code != http.StatusRequestTimeout && code != http.StatusTooManyRequests {
+			return false
+		}
+	}
+	return true
+}
+
+// TimeoutPathChecker wraps a PathChecker and gives up on checks that take longer than the timeout,
+// so one hung request can't stall a whole load. A check that times out is a miss for FileExists
+// and an error for FileExistsErr.
+//
+// The wrapped check runs in its own goroutine. When it times out the goroutine is abandoned rather
+// than stopped: it's cancelled through the context if the wrapped PathChecker is a
+// ContextPathChecker, but otherwise lingers until the check returns by itself. A ContextPathChecker
+// is given the context even if it's also an ErrorPathChecker or a Statter, so when it only has
+// FileExistsContext its lookup errors are misses. The package's own checkers keep their errors.
+type TimeoutPathChecker struct {
+	pc      PathChecker
+	timeout time.Duration
+}
+
+// NewTimeoutPathChecker wraps pc, giving each check at most timeout
+func NewTimeoutPathChecker(pc PathChecker, timeout time.Duration) TimeoutPathChecker {
+	return TimeoutPathChecker{pc: pc, timeout: timeout}
+}
+
+// FileExists returns whether the file exists, or false if the check failed or timed out
+func (tp TimeoutPathChecker) FileExists(path string) bool {
+	return existsOrFalse(tp.FileExistsErr(path))
+}
+
+// FileExistsErr returns whether the file exists, or an error if the check failed or timed out
+func (tp TimeoutPathChecker) FileExistsErr(path string) (bool, error) {
+	return tp.fileExists(context.Background(), path)
+}
+
+// FileExistsContext is FileExists, also giving up when the context is done
+func (tp TimeoutPathChecker) FileExistsContext(ctx context.Context, path string) bool {
+	return existsOrFalse(tp.fileExists(ctx, path))
+}
+
+func (tp TimeoutPathChecker) fileExists(ctx context.Context, path string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, tp.timeout)
+	defer cancel()
+	exists, _, err := lookupContext(ctx, func() (bool, S3ObjectInfo, error) {
+		switch c := tp.pc.(type) {
+		case errorContextPathChecker:
+			exists, err := c.fileExists(ctx, path)
+			return exists, S3ObjectInfo{}, err
+		case ContextPathChecker:
+			return c.FileExistsContext(ctx, path), S3ObjectInfo{}, nil
+		default:
+			return lookup(ctx, tp.pc, path)
+		}
+	})
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return false, fmt.Errorf("timed out after %s checking %s: %w", tp.timeout, path, err)
+	}
+	return exists, err
+}
+
+// PrefixPathChecker answers existence checks by listing the folder a path is in once and
+// remembering the keys, so looking for all the suffixes (and the config file) of a table's
+// data costs one ListObjects instead of a HEAD each. The listing is kept until a path in a
+// different folder is checked, so it suits resolving files one folder at a time.
+// A file that lands after its folder was listed isn't seen until Refresh is called.
+// It is safe for concurrent use.
+type PrefixPathChecker struct {
+	lister Lister
+
+	mu     sync.Mutex
+	prefix string
+	keys   map[string]bool
+}
+
+// NewPrefixPathChecker returns a PrefixPathChecker that lists folders with lister, e.g. S3Lister{}
+func NewPrefixPathChecker(lister Lister) *PrefixPathChecker {
+	return &PrefixPathChecker{lister: lister}
+}
+
+// FileExists returns whether the path is in its folder's listing, treating a failed listing as missing
+func (pp *PrefixPathChecker) FileExists(path string) bool {
+	exists, _ := pp.FileExistsErr(path)
+	return exists
+}
+
+// FileExistsErr returns whether the path is in its folder's listing, listing the folder
+// if it isn't the one that was listed last
+func (pp *PrefixPathChecker) FileExistsErr(path string) (bool, error) {
+	prefix := path[:strings.LastIndex(path, "/")+1]
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	if pp.keys == nil || prefix != pp.prefix {
+		paths, err := pp.lister.List(prefix)
+		if err != nil {
+			return false, fmt.Errorf("error listing %s: %w", prefix, err)
+		}
+		pp.prefix = prefix
+		pp.keys = make(map[string]bool, len(paths))
+		for _, p := range paths {
+			pp.keys[p] = true
+		}
+	}
+	return pp.keys[path], nil
+}
+
+// Refresh forgets the listing, so the next check lists its folder again
+func (pp *PrefixPathChecker) Refresh() {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	pp.prefix = ""
+	pp.keys = nil
+}
@@ -0,0 +1,317 @@
+package s3filepath
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalPathChecker(t *testing.T) {
+	root, err := ioutil.TempDir("", "s3filepath")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json.gz")
+	dir := filepath.Join(root, "b", filepath.FromSlash(f.Subfolder))
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "s_t_2015-11-10T23:00:00Z.json.gz"), []byte("{}"), 0644))
+
+	pc := LocalPathChecker{Root: root}
+	assert.True(t, pc.FileExists(f.GetDataFilename()))
+	assert.False(t, pc.FileExists("s3://b/"+f.Subfolder+"/s_t_2015-11-10T23:00:00Z.json"))
+	// directories aren't files
+	assert.False(t, pc.FileExists("s3://b/"+f.Subfolder))
+
+	// the whole discovery flow works offline
+	found, err := CreateS3File(pc, S3Bucket{Name: "b"}, "s", "t", "", expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, f, found)
+}
+
+func TestInMemoryPathChecker(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	candidates := CandidateDataPaths(bucket, "s", "t", expectedDate)
+	pc := NewInMemoryPathChecker(candidates[2])
+
+	f, err := CreateS3File(pc, bucket, "s", "t", "", expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, candidates[2], f.GetDataFilename())
+	// the data files are checked in order, then the config files
+	checked := pc.Checked()
+	assert.Equal(t, candidates[:3], checked[:3])
+	assert.Equal(t, f.GetConfigFilename(), checked[3])
+	assert.Equal(t, 1, pc.Checks(candidates[0]))
+	assert.Equal(t, 0, pc.Checks(candidates[3]))
+
+	pc.ResetChecks()
+	pc.Remove(candidates[2])
+	pc.Add(candidates[0])
+	assert.Empty(t, pc.Checked())
+	assert.True(t, pc.FileExists(candidates[0]))
+	assert.False(t, pc.FileExists(candidates[2]))
+	assert.False(t, pc.FileExists(candidates[0]+".other"))
+	assert.Equal(t, []string{candidates[0], candidates[2], candidates[0] + ".other"}, pc.Checked())
+
+	// failures come first, whatever the path, and a nil one answers as usual
+	denied := errors.New("access denied")
+	pc.Fail(denied, nil)
+	exists, err := pc.FileExistsErr(candidates[0])
+	assert.False(t, exists)
+	assert.Equal(t, denied, err)
+	exists, err = pc.FileExistsErr(candidates[0])
+	assert.True(t, exists)
+	assert.NoError(t, err)
+
+	// it lists the paths that exist
+	pc.Add(candidates[1], "s3://b/other/t")
+	listed, err := pc.List("s3://b/s/t/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{candidates[1], candidates[0]}, listed)
+	assert.Equal(t, []string{"s3://b/s/t/"}, pc.Listed())
+}
+
+func TestCachingPathChecker(t *testing.T) {
+	inner := NewInMemoryPathChecker("s3://b/found")
+	now := time.Date(2015, time.November, 10, 0, 0, 0, 0, time.UTC)
+	pc := NewCachingPathChecker(inner, time.Minute)
+	pc.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, pc.FileExists("s3://b/found"))
+		assert.False(t, pc.FileExists("s3://b/missing"))
+	}
+	assert.Equal(t, 1, inner.Checks("s3://b/found"))
+	assert.Equal(t, 1, inner.Checks("s3://b/missing"))
+
+	// the file lands, but we keep the cached miss until it's invalidated
+	inner.Add("s3://b/missing")
+	assert.False(t, pc.FileExists("s3://b/missing"))
+	pc.Invalidate("s3://b/missing")
+	assert.True(t, pc.FileExists("s3://b/missing"))
+	assert.Equal(t, 2, inner.Checks("s3://b/missing"))
+
+	// results expire after the ttl
+	now = now.Add(2 * time.Minute)
+	assert.True(t, pc.FileExists("s3://b/found"))
+	assert.Equal(t, 2, inner.Checks("s3://b/found"))
+
+	// no ttl means results never expire
+	forever := NewCachingPathChecker(inner, 0)
+	assert.True(t, forever.FileExists("s3://b/found"))
+	assert.True(t, forever.FileExists("s3://b/found"))
+	assert.Equal(t, 3, inner.Checks("s3://b/found"))
+}
+
+func TestCachingPathCheckerErrors(t *testing.T) {
+	denied := awserr.NewRequestFailure(awserr.New("AccessDenied", "access denied", nil), 403, "id")
+	inner := NewInMemoryPathChecker("s3://b/found")
+	inner.Fail(denied)
+	pc := NewCachingPathChecker(inner, 0)
+
+	// a failed lookup is returned, and isn't cached as a miss
+	exists, err := pc.FileExistsErr("s3://b/found")
+	assert.False(t, exists)
+	assert.Equal(t, denied, err)
+	assert.True(t, pc.FileExists("s3://b/found"))
+	assert.True(t, pc.FileExists("s3://b/found"))
+	assert.Equal(t, 2, len(inner.Checked()))
+
+	// CreateS3File sees the error through the cache
+	inner = newFailingPathChecker(denied)
+	_, err = CreateS3File(NewCachingPathChecker(inner, 0), S3Bucket{Name: "b"}, "s", "t", "", expectedDate)
+	var reqErr awserr.RequestFailure
+	assert.True(t, errors.As(err, &reqErr))
+	assert.False(t, errors.Is(err, ErrFileNotFound))
+}
+
+func TestCachingPathCheckerStat(t *testing.T) {
+	jsonPath := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z.json"
+	info := S3ObjectInfo{Size: 1024, LastModified: expectedDate, ETag: "abc"}
+	inner := &mockStatter{objects: map[string]S3ObjectInfo{jsonPath: info}}
+	pc := NewCachingPathChecker(inner, 0)
+
+	// CreateS3File gets the metadata through the cache, and the second lookup is cached
+	f, err := CreateS3File(pc, S3Bucket{Name: "b"}, "s", "t", "", expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, info, f.Object)
+	calls := inner.calls
+	f, err = CreateS3File(pc, S3Bucket{Name: "b"}, "s", "t", "", expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, info, f.Object)
+	assert.Equal(t, calls, inner.calls)
+
+	// a miss is cached and still looks like one
+	_, err = pc.Stat("s3://b/missing")
+	assert.True(t, isNotFound(err))
+	_, err = pc.Stat("s3://b/missing")
+	assert.True(t, isNotFound(err))
+	assert.Equal(t, calls+1, inner.calls)
+
+	// a checker that can't Stat has no metadata, but still reports misses
+	plain := NewCachingPathChecker(MockPathChecker{map[string]bool{jsonPath: true}}, 0)
+	found, err := plain.Stat(jsonPath)
+	assert.NoError(t, err)
+	assert.Equal(t, S3ObjectInfo{}, found)
+	_, err = plain.Stat("s3://b/missing")
+	assert.True(t, isNotFound(err))
+}
+
+func TestRetryingPathChecker(t *testing.T) {
+	throttled := awserr.NewRequestFailure(awserr.New("SlowDown", "slow down", nil), 503, "id")
+	denied := awserr.NewRequestFailure(awserr.New("AccessDenied", "access denied", nil), 403, "id")
+
+	var sleeps []time.Duration
+	newChecker := func(inner ErrorPathChecker) *RetryingPathChecker {
+		rp := NewRetryingPathChecker(inner, 4, time.Second)
+		rp.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+		return rp
+	}
+
+	// transient errors are retried with exponential backoff
+	inner := NewInMemoryPathChecker("s3://b/k")
+	inner.Fail(throttled, errors.New("timeout"))
+	exists, err := newChecker(inner).FileExistsErr("s3://b/k")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, 3, len(inner.Checked()))
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, sleeps)
+
+	// a missing file isn't retried
+	sleeps = nil
+	inner = NewInMemoryPathChecker()
+	exists, err = newChecker(inner).FileExistsErr("s3://b/k")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assert.Equal(t, 1, len(inner.Checked()))
+
+	// neither is a permissions error
+	inner = NewInMemoryPathChecker("s3://b/k")
+	inner.Fail(denied)
+	exists, err = newChecker(inner).FileExistsErr("s3://b/k")
+	assert.Equal(t, denied, err)
+	assert.False(t, exists)
+	assert.Equal(t, 1, len(inner.Checked()))
+
+	// give up after max attempts
+	inner = NewInMemoryPathChecker("s3://b/k")
+	inner.Fail(throttled, throttled, throttled, throttled, throttled)
+	assert.False(t, newChecker(inner).FileExists("s3://b/k"))
+	assert.Equal(t, 4, len(inner.Checked()))
+	assert.Len(t, sleeps, 3)
+}
+
+// slowPathChecker takes delay to answer
+type slowPathChecker struct {
+	delay  time.Duration
+	exists bool
+}
+
+func (sp slowPathChecker) FileExists(path string) bool {
+	time.Sleep(sp.delay)
+	return sp.exists
+}
+
+func TestTimeoutPathChecker(t *testing.T) {
+	pc := NewTimeoutPathChecker(slowPathChecker{delay: time.Millisecond, exists: true}, time.Second)
+	exists, err := pc.FileExistsErr("s3://b/k")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	pc = NewTimeoutPathChecker(slowPathChecker{delay: time.Second, exists: true}, 10*time.Millisecond)
+	start := time.Now()
+	exists, err = pc.FileExistsErr("s3://b/k")
+	assert.True(t, time.Since(start) < 500*time.Millisecond)
+	assert.False(t, exists)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.EqualError(t, err, "timed out after 10ms checking s3://b/k: context deadline exceeded")
+	assert.False(t, pc.FileExists("s3://b/k"))
+
+	// a cancelled context isn't a timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, pc.FileExistsContext(ctx, "s3://b/k"))
+	_, err = pc.fileExists(ctx, "s3://b/k")
+	assert.Equal(t, context.Canceled, err)
+
+	// errors from the wrapped checker come through
+	denied := errors.New("access denied")
+	pc = NewTimeoutPathChecker(newFailingPathChecker(denied), time.Second)
+	_, err = pc.FileExistsErr("s3://b/k")
+	assert.Equal(t, denied, err)
+}
+
+// hangingPathChecker never answers, except to FileExistsContext once its context is done
+type hangingPathChecker struct {
+	cancelled chan error
+}
+
+func (hp hangingPathChecker) FileExists(path string) bool {
+	select {}
+}
+
+func (hp hangingPathChecker) FileExistsErr(path string) (bool, error) {
+	select {}
+}
+
+func (hp hangingPathChecker) Stat(path string) (S3ObjectInfo, error) {
+	select {}
+}
+
+func (hp hangingPathChecker) FileExistsContext(ctx context.Context, path string) bool {
+	<-ctx.Done()
+	hp.cancelled <- ctx.Err()
+	return false
+}
+
+func TestTimeoutPathCheckerCancels(t *testing.T) {
+	// it's also an ErrorPathChecker and a Statter, but the timeout still reaches it
+	inner := hangingPathChecker{cancelled: make(chan error, 1)}
+	_, err := NewTimeoutPathChecker(inner, 10*time.Millisecond).FileExistsErr("s3://b/k")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	select {
+	case err := <-inner.cancelled:
+		assert.Equal(t, context.DeadlineExceeded, err)
+	case <-time.After(time.Second):
+		t.Fatal("the wrapped check wasn't cancelled")
+	}
+}
+
+func TestPrefixPathChecker(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	folder := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/"
+	csvPath := folder + "s_t_2015-11-10T23:00:00Z"
+	lister := NewInMemoryPathChecker(csvPath)
+	pc := NewPrefixPathChecker(lister)
+
+	// every default suffix and the config file are checked with one listing
+	f, err := CreateS3File(pc, bucket, "s", "t", "", expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, csvPath, f.GetDataFilename())
+	assert.Equal(t, []string{folder}, lister.Listed())
+
+	// another day is another folder
+	exists, err := pc.FileExistsErr("s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=11/s_t.json")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assert.True(t, pc.FileExists(csvPath))
+	assert.Len(t, lister.Listed(), 3)
+
+	// files that land later are seen after a refresh
+	lister.Add(folder + "s_t_2015-11-10T23:00:00Z.json")
+	assert.False(t, pc.FileExists(folder+"s_t_2015-11-10T23:00:00Z.json"))
+	pc.Refresh()
+	assert.True(t, pc.FileExists(folder+"s_t_2015-11-10T23:00:00Z.json"))
+	assert.Len(t, lister.Listed(), 4)
+
+	pc = NewPrefixPathChecker(MockLister{Err: errors.New("access denied")})
+	_, err = pc.FileExistsErr(csvPath)
+	assert.EqualError(t, err, "error listing "+folder+": access denied")
+	assert.False(t, pc.FileExists(csvPath))
+}
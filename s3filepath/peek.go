@@ -0,0 +1,39 @@
+package s3filepath
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// Peek returns at most the first n bytes of the data file's content, e.g. to sniff a csv header
+// or the first JSON object before running a COPY. Gzipped files (by suffix, see Compression) are
+// gunzipped as they're read, so the bytes are always decompressed ones; only as much of the file
+// as it takes to get them is downloaded. Other compressions are returned as they are.
+func (f *S3File) Peek(reader ReaderProvider, n int) ([]byte, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("peek size must be positive, got %d", n)
+	}
+	dataPath := f.GetDataFilename()
+	r, err := reader.Reader(dataPath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %w", dataPath, err)
+	}
+	defer r.Close()
+
+	var content io.Reader = r
+	if f.Compression() == CompressionGzip {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("error gunzipping %s: %w", dataPath, err)
+		}
+		defer gz.Close()
+		content = gz
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(content, int64(n)))
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", dataPath, err)
+	}
+	return data, nil
+}
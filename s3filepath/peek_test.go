@@ -0,0 +1,93 @@
+package s3filepath
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// closeTrackingReaderProvider serves fixed content and records whether the reader was closed
+type closeTrackingReaderProvider struct {
+	content []byte
+	closed  bool
+}
+
+func (c *closeTrackingReaderProvider) Reader(path string) (io.ReadCloser, error) {
+	return &closeTrackingReader{Reader: bytes.NewReader(c.content), provider: c}, nil
+}
+
+type closeTrackingReader struct {
+	io.Reader
+	provider *closeTrackingReaderProvider
+}
+
+func (c *closeTrackingReader) Close() error {
+	c.provider.closed = true
+	return nil
+}
+
+func gzipped(t *testing.T, content string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(content))
+	assert.NoError(t, err)
+	assert.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestPeek(t *testing.T) {
+	content := "id,name\n1,a\n2,b\n"
+
+	// plain
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "")
+	reader := &closeTrackingReaderProvider{content: []byte(content)}
+	data, err := f.Peek(reader, 8)
+	assert.NoError(t, err)
+	assert.Equal(t, "id,name\n", string(data))
+	assert.True(t, reader.closed)
+
+	// asking for more than there is returns it all
+	data, err = f.Peek(reader, 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, content, string(data))
+
+	// gzipped content is gunzipped, and n counts decompressed bytes
+	f = buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, ".gz")
+	reader = &closeTrackingReaderProvider{content: gzipped(t, content)}
+	data, err = f.Peek(reader, 8)
+	assert.NoError(t, err)
+	assert.Equal(t, "id,name\n", string(data))
+	assert.True(t, reader.closed)
+
+	f = buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json.gz")
+	data, err = f.Peek(&closeTrackingReaderProvider{content: gzipped(t, `{"id": 1}`+"\n"+`{"id": 2}`)}, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id": 1}`+"\n", string(data))
+
+	// a .gz file that isn't gzipped
+	reader = &closeTrackingReaderProvider{content: []byte(content)}
+	_, err = f.Peek(reader, 8)
+	assert.Error(t, err)
+	assert.True(t, reader.closed)
+
+	_, err = f.Peek(reader, 0)
+	assert.Error(t, err)
+	_, err = f.Peek(mockReaderProvider{err: errors.New("access denied")}, 8)
+	assert.Error(t, err)
+}
+
+func TestPeekReadsOnlyWhatItNeeds(t *testing.T) {
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json")
+	var data []byte
+	var err error
+	used := allocated(func() {
+		data, err = f.Peek(syntheticReaderProvider{size: 64000000}, 16)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "xxxxxxxxx\nxxxxxx", string(data))
+	assert.True(t, used < 1<<20, "peeking allocated %d bytes", used)
+}
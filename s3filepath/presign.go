@@ -0,0 +1,42 @@
+package s3filepath
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Presigner makes temporary download URLs for files
+type Presigner interface {
+	// Presign returns a URL anyone can download the file at for the next ttl
+	Presign(path string, ttl time.Duration) (string, error)
+}
+
+// S3Presigner presigns GetObject requests with the caller's AWS credentials.
+// The URL stops working when the ttl is up, or earlier if the credentials expire first.
+type S3Presigner struct{}
+
+// Presign returns a presigned GetObject URL for the s3 path
+func (S3Presigner) Presign(path string, ttl time.Duration) (string, error) {
+	bucket, key, err := splitS3Path(path)
+	if err != nil {
+		return "", err
+	}
+	client, err := s3ClientForBucket(bucket)
+	if err != nil {
+		return "", err
+	}
+	req, _ := client.GetObjectRequest(&s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	return req.Presign(ttl)
+}
+
+// PresignDataURL returns a temporary download URL for the S3File's data file
+func (f *S3File) PresignDataURL(p Presigner, ttl time.Duration) (string, error) {
+	url, err := p.Presign(f.GetDataFilename(), ttl)
+	if err != nil {
+		return "", fmt.Errorf("error presigning %s: %w", f.GetDataFilename(), err)
+	}
+	return url, nil
+}
@@ -0,0 +1,40 @@
+package s3filepath
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockPresigner struct {
+	url  string
+	err  error
+	path string
+	ttl  time.Duration
+}
+
+func (m *mockPresigner) Presign(path string, ttl time.Duration) (string, error) {
+	m.path, m.ttl = path, ttl
+	return m.url, m.err
+}
+
+func TestPresignDataURL(t *testing.T) {
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json.gz")
+	p := &mockPresigner{url: "https://b.s3.amazonaws.com/signed"}
+	url, err := f.PresignDataURL(p, 15*time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://b.s3.amazonaws.com/signed", url)
+	assert.Equal(t, f.GetDataFilename(), p.path)
+	assert.Equal(t, 15*time.Minute, p.ttl)
+
+	p = &mockPresigner{err: errors.New("no credentials")}
+	_, err = f.PresignDataURL(p, time.Minute)
+	assert.EqualError(t, err, "error presigning "+f.GetDataFilename()+": no credentials")
+}
+
+func TestS3PresignerBadPath(t *testing.T) {
+	_, err := S3Presigner{}.Presign("b/s/t/file.json", time.Minute)
+	assert.EqualError(t, err, "not an s3 path: b/s/t/file.json")
+}
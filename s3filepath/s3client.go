@@ -0,0 +1,47 @@
+package s3filepath
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// bucketClients caches an s3 client per bucket, since each one needs the bucket's region
+var bucketClients sync.Map
+
+// splitS3Path splits s3://bucket/key into the bucket and the key
+func splitS3Path(path string) (string, string, error) {
+	if !strings.HasPrefix(path, "s3://") {
+		return "", "", fmt.Errorf("not an s3 path: %s", path)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(path, "s3://"), "/", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", fmt.Errorf("s3 path has no bucket and key: %s", path)
+	}
+	return parts[0], parts[1], nil
+}
+
+// s3ClientForBucket returns an s3 client in the bucket's region
+func s3ClientForBucket(bucket string) (*s3.S3, error) {
+	if client, ok := bucketClients.Load(bucket); ok {
+		return client.(*s3.S3), nil
+	}
+	// Any region will work for the region lookup, but the request MUST use
+	// PathStyle
+	lookup := s3.New(session.New(), aws.NewConfig().WithRegion("us-west-1").WithS3ForcePathStyle(true))
+	resp, err := lookup.GetBucketLocation(&s3.GetBucketLocationInput{Bucket: aws.String(bucket)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get location for bucket '%s', %s", bucket, err)
+	}
+	// "US Standard", returns an empty region. So use any region in the US
+	region := "us-east-1"
+	if resp.LocationConstraint != nil {
+		region = *resp.LocationConstraint
+	}
+	client, _ := bucketClients.LoadOrStore(bucket, s3.New(session.New(), aws.NewConfig().WithRegion(region)))
+	return client.(*s3.S3), nil
+}
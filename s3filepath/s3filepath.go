@@ -1,24 +1,233 @@
 package s3filepath
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"os"
+	"path"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Clever/pathio"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 )
 
 var (
+	// matches the part of a data filename after the schema_table_ prefix.
+	// It's anchored on the RFC3339 date rather than on underscores, since
+	// schema and table names can contain underscores themselves.
 	// currently assumes no unix file created timestamp
-	s3Regex   = regexp.MustCompile(".*_.*_(.*?)\\.(.*)")
-	yamlRegex = regexp.MustCompile(".*\\.yml")
+	// the suffix is optional since UNLOADed csv files don't have one
+	s3Regex = regexp.MustCompile("^([0-9]{4}-[0-9]{2}-[0-9]{2}T[^._]+)(?:\\.(.*))?$")
+	// the same for dates in epoch seconds or milliseconds
+	epochRegex = regexp.MustCompile(`^([0-9]+)(?:\.(.*))?$`)
+	// and for any other date layout, assuming there's no dot in the formatted date
+	dateRegex = regexp.MustCompile(`^([^.]+)(?:\.(.*))?$`)
+	yamlRegex = regexp.MustCompile(".*\\.ya?ml")
+
+	// config files are looked for with these extensions, in order
+	configExtensions = []string{"yml", "yaml"}
+
+	// Try to find manifest or data files out of the following patterns, in order
+	// we try to get in order as otherwise
+	defaultSuffixes = []string{
+		"manifest", // 1) manifest file
+		"json.gz",  // 2) gzipped json file
+		"json",     // 3) json file
+		".gz",      // 4) gzipped csv file (.gz)
+		"",         // 5) csv file (no suffix when UNLOADed :-/)
+	}
+
+	// extendedSuffixes are the default suffixes plus the bzip2, zstd and parquet ones, see
+	// WithExtendedSuffixes. They aren't defaults since the main binary's COPY only loads
+	// json and csv that's uncompressed or gzipped.
+	extendedSuffixes = []string{
+		"manifest",   // 1) manifest file
+		"json.gz",    // 2) gzipped json file
+		"json.bz2",   // 3) bzip2ed json file
+		"json.zst",   // 4) zstd compressed json file
+		"json",       // 5) json file
+		"parquet.gz", // 6) gzipped parquet file
+		"parquet",    // 7) parquet file
+		".gz",        // 8) gzipped csv file (.gz)
+		".bz2",       // 9) bzip2ed csv file (.bz2)
+		".zst",       // 10) zstd compressed csv file (.zst)
+		"",           // 11) csv file (no suffix when UNLOADed :-/)
+	}
+)
+
+// FileFormat is the format of the data in an S3File, as determined by its suffix
+type FileFormat int
+
+const (
+	// FormatUnknown is used for manifest files, which obscure the underlying file types
+	FormatUnknown FileFormat = iota
+	// FormatCSV is used for plain or gzipped csv files
+	FormatCSV
+	// FormatJSON is used for json and json.gz files
+	FormatJSON
+	// FormatParquet is used for parquet and parquet.gz files
+	FormatParquet
+)
+
+// String returns the name Redshift uses for the format, e.g. in FORMAT AS PARQUET,
+// or FileFormat(n) if it isn't a known format
+func (ff FileFormat) String() string {
+	switch ff {
+	case FormatUnknown:
+		return ""
+	case FormatCSV:
+		return "CSV"
+	case FormatJSON:
+		return "JSON"
+	case FormatParquet:
+		return "PARQUET"
+	default:
+		return fmt.Sprintf("FileFormat(%d)", int(ff))
+	}
+}
+
+// DateLayouts for filenames dated with seconds or milliseconds since the unix epoch
+const (
+	DateLayoutEpochSeconds = "epoch-seconds"
+	DateLayoutEpochMillis  = "epoch-millis"
 )
 
+// ErrFileNotFound matches (with errors.Is) the error returned when there's no data file
+// for a table and date. Any other error means the lookup itself failed, e.g. because
+// we don't have permission to read the bucket, and wraps the underlying error.
+var ErrFileNotFound = errors.New("s3 file not found")
+
+// ErrConfigNotFound matches (with errors.Is) the error returned by lookups using WithRequireConfig
+// when the data file exists but its config file doesn't
+var ErrConfigNotFound = errors.New("config file not found")
+
+// AmbiguousFileError is returned by lookups using WithAmbiguityCheck when a manifest and data
+// files for the same table and date are all there, so loading the manifest might load the data twice
+type AmbiguousFileError struct {
+	Manifest string
+	// DataFiles are the other data files found, in the order of the suffixes checked
+	DataFiles []string
+}
+
+func (e *AmbiguousFileError) Error() string {
+	return fmt.Sprintf("both manifest %s and data file %s exist, only one of them should be loaded",
+		e.Manifest, strings.Join(e.DataFiles, ", "))
+}
+
+// FileNotFoundError is returned when there's no data file for a table and date
+type FileNotFoundError struct {
+	Bucket string
+	Schema string
+	Table  string
+	Date   time.Time
+	// Candidates are the paths that were checked, in order
+	Candidates []string
+}
+
+func (e *FileNotFoundError) Error() string {
+	return fmt.Sprintf("s3 file not found at: bucket: %s schema: %s, table: %s date: %s, tried: %s",
+		e.Bucket, e.Schema, e.Table, e.Date.Format(time.RFC3339), strings.Join(e.Candidates, ", "))
+}
+
+// Is makes FileNotFoundErrors match ErrFileNotFound
+func (e *FileNotFoundError) Is(target error) bool {
+	return target == ErrFileNotFound
+}
+
+// LookupAttempt is the outcome of looking for the data file with one suffix
+type LookupAttempt struct {
+	Suffix string
+	Path   string
+	Found  bool
+	// Err is set when the lookup itself failed, rather than the file not being there
+	Err error
+}
+
+// LookupError is returned by CreateS3File and FindS3Files when they don't find a data file, with
+// every suffix they tried and what happened. A lookup that fails stops the search, so it's the
+// last attempt. It wraps a FileNotFoundError when every attempt was a miss, and the failed lookup's
+// error otherwise, so errors.Is(err, ErrFileNotFound) still tells the two apart.
+type LookupError struct {
+	Attempts []LookupAttempt
+	err      error
+}
+
+func (e *LookupError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the FileNotFoundError, or the error the failed lookup returned
+func (e *LookupError) Unwrap() error {
+	return e.err
+}
+
+// Errored returns whether any of the attempts failed, rather than just not finding the file
+func (e *LookupError) Errored() bool {
+	for _, attempt := range e.Attempts {
+		if attempt.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrNoRecentFile matches (with errors.Is) the error returned by FindLatestFile when there's
+// no data file for the table on any of the days checked
+var ErrNoRecentFile = errors.New("no recent s3 file found")
+
+// NoRecentFileError is returned by FindLatestFile when there's no data file for the table
+// between From and To, inclusive
+type NoRecentFileError struct {
+	Bucket string
+	Schema string
+	Table  string
+	From   time.Time
+	To     time.Time
+}
+
+func (e *NoRecentFileError) Error() string {
+	return fmt.Sprintf("no s3 file found for bucket: %s schema: %s, table: %s between %s and %s",
+		e.Bucket, e.Schema, e.Table, e.From.Format(time.RFC3339), e.To.Format(time.RFC3339))
+}
+
+// Is makes NoRecentFileErrors match ErrNoRecentFile
+func (e *NoRecentFileError) Is(target error) bool {
+	return target == ErrNoRecentFile
+}
+
 // S3Bucket is our subset of the s3.Bucket class, useful for testing mostly
 type S3Bucket struct {
-	Name            string
-	Region          string
-	RedshiftRoleARN string
+	Name            string `json:"name"`
+	Region          string `json:"region,omitempty"`
+	RedshiftRoleARN string `json:"redshift_role_arn,omitempty"`
+	// SSEMode and KMSKeyID are the server side encryption to write objects with, see Encryption
+	SSEMode  string `json:"sse_mode,omitempty"`
+	KMSKeyID string `json:"kms_key_id,omitempty"`
+	// Prefix is the folder in the bucket everything is under, e.g. "datalake/prod".
+	// Every path built for the bucket starts with it; if it's empty paths start at the bucket root.
+	Prefix string `json:"prefix,omitempty"`
+	// Scheme is the scheme of the bucket's paths, SchemeS3 if empty.
+	// Redshift can only COPY from S3, but paths and lookups work the same in GCS.
+	Scheme string `json:"scheme,omitempty"`
+}
+
+// The schemes a bucket's paths can use
+const (
+	SchemeS3  = "s3"
+	SchemeGCS = "gs"
+)
+
+// scheme returns the bucket's Scheme, defaulting to SchemeS3
+func (b S3Bucket) scheme() string {
+	if b.Scheme == "" {
+		return SchemeS3
+	}
+	return b.Scheme
 }
 
 // S3File holds everything needed to run a COPY on the file
@@ -31,57 +240,953 @@ type S3File struct {
 	DataDate  time.Time
 	Subfolder string
 	ConfFile  string
+	// DateLayout is how DataDate is formatted in the data and config filenames.
+	// Either a time.Format layout, DateLayoutEpochSeconds or DateLayoutEpochMillis; defaults to time.RFC3339.
+	DateLayout string
+	// Object is filled in when the file was found by a PathChecker that is also a Statter
+	Object S3ObjectInfo
+	// Part is set for one part of data written in several, e.g. "part-00000", see FindParts.
+	// The data file is then Part.Suffix in the Subfolder, rather than schema_table_date.Suffix.
+	Part string
+
+	// granularity is how finely the default Subfolder is partitioned, see WithPartitionGranularity
+	granularity PartitionGranularity
+	// partitionKeys are the names of the default Subfolder's folders, see WithPartitionKeys
+	partitionKeys PartitionKeys
+	// uppercaseSuffixes is set by WithUppercaseSuffixes
+	uppercaseSuffixes bool
+	// extendedSuffixes is set by WithExtendedSuffixes
+	extendedSuffixes bool
+	// configNamer is set by WithConfigNamer
+	configNamer ConfigNamer
+	// requireConfig is set by WithRequireConfig
+	requireConfig bool
+	// separator goes between the parts of the filenames, see WithSeparator
+	separator string
+	// withoutSchemaPrefix is set by WithoutSchemaPrefix
+	withoutSchemaPrefix bool
+	// ambiguityCheck is set by WithAmbiguityCheck
+	ambiguityCheck bool
+	// explicitSubfolder is set by WithExplicitSubfolder, Subfolder is used even if it's empty
+	explicitSubfolder bool
+	// readBufferSize is set by WithReadBufferSize
+	readBufferSize int
 }
 
 // PathChecker is the interface for determining if a path in S3 exists, which allows
-// DI for testing.
+// DI for testing. PathCheckers used with CreateS3Files must be safe for concurrent use.
 type PathChecker interface {
 	FileExists(path string) bool
 }
 
+// Lister is the interface for listing what's under a prefix in S3, which allows
+// DI for testing. The prefix and the returned paths are full s3:// paths.
+type Lister interface {
+	List(prefix string) ([]string, error)
+}
+
+// ContextPathChecker is a PathChecker whose existence checks can be aborted by
+// cancelling the context. CreateS3FileContext uses it when available.
+type ContextPathChecker interface {
+	PathChecker
+	FileExistsContext(ctx context.Context, path string) bool
+}
+
+// errorContextPathChecker is a ContextPathChecker that can also return lookup errors,
+// like the package's own checkers
+type errorContextPathChecker interface {
+	ContextPathChecker
+	fileExists(ctx context.Context, path string) (bool, error)
+}
+
 // S3PathChecker will use pathio to determine if the path actually exists in S3, and
-// will be used in prod.
+// will be used in prod. It has no state of its own and pathio.Reader is safe to call from
+// several goroutines at once, so it's safe for concurrent use. The package Logger it reports
+// errors to can be swapped with SetLogger at any time.
+// It isn't a Statter, so files it finds have no Object, see S3StatPathChecker.
 type S3PathChecker struct{}
 
+// ErrorPathChecker is a PathChecker that can also tell a missing file apart from
+// a failed lookup. A missing file is (false, nil); anything else that went wrong
+// (permissions, throttling, network) is returned as the error.
+type ErrorPathChecker interface {
+	PathChecker
+	FileExistsErr(path string) (bool, error)
+}
+
 // FileExists looks up if the file exists in S3 using the pathio.Reader method.
-func (S3PathChecker) FileExists(path string) bool {
+// Errors count as the file not existing, but are sent to the package Logger.
+func (pc S3PathChecker) FileExists(path string) bool {
+	exists, err := pc.FileExistsErr(path)
+	if err != nil {
+		currentLogger().Log("s3file-exists-error", map[string]interface{}{"path": path, "error": err.Error()})
+	}
+	return exists
+}
+
+// FileExistsErr looks up if the file exists in S3 using the pathio.Reader method,
+// returning the error from pathio unless it just means the file isn't there.
+func (S3PathChecker) FileExistsErr(path string) (bool, error) {
 	reader, err := pathio.Reader(path)
 	if reader != nil {
 		defer reader.Close()
 	}
-	return err == nil
+	if err == nil {
+		return true, nil
+	}
+	if isNotFound(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// isNotFound returns whether the error from pathio means the file doesn't exist
+func isNotFound(err error) bool {
+	if os.IsNotExist(err) {
+		return true
+	}
+	var reqErr awserr.RequestFailure
+	if errors.As(err, &reqErr) && reqErr.StatusCode() == http.StatusNotFound {
+		return true
+	}
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		switch awsErr.Code() {
+		case "NoSuchKey", "NotFound":
+			return true
+		}
+	}
+	return false
+}
+
+// FileExistsContext is FileExists, but returns false as soon as the context is done.
+// pathio can't be cancelled, so the lookup itself finishes in the background.
+func (pc S3PathChecker) FileExistsContext(ctx context.Context, path string) bool {
+	exists, _ := fileExistsErrContext(ctx, pc, path)
+	return exists
+}
+
+// fileExists is FileExistsErr, but returns as soon as the context is done
+func (pc S3PathChecker) fileExists(ctx context.Context, path string) (bool, error) {
+	return fileExistsErrContext(ctx, pc, path)
+}
+
+// fileExistsErrContext runs FileExistsErr in the background so that we can stop
+// waiting for it when the context is done
+func fileExistsErrContext(ctx context.Context, pc ErrorPathChecker, path string) (bool, error) {
+	exists, _, err := lookupContext(ctx, func() (bool, S3ObjectInfo, error) {
+		exists, err := pc.FileExistsErr(path)
+		return exists, S3ObjectInfo{}, err
+	})
+	return exists, err
+}
+
+// lookupContext runs the lookup in the background so that we can stop
+// waiting for it when the context is done
+func lookupContext(ctx context.Context, lookup func() (bool, S3ObjectInfo, error)) (bool, S3ObjectInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return false, S3ObjectInfo{}, err
+	}
+	type result struct {
+		exists bool
+		info   S3ObjectInfo
+		err    error
+	}
+	found := make(chan result, 1)
+	go func() {
+		exists, info, err := lookup()
+		found <- result{exists, info, err}
+	}()
+	select {
+	case r := <-found:
+		return r.exists, r.info, r.err
+	case <-ctx.Done():
+		return false, S3ObjectInfo{}, ctx.Err()
+	}
+}
+
+// fileExists checks the path with the context and returns lookup errors
+// if the PathChecker supports them
+func fileExists(ctx context.Context, pc PathChecker, path string) (bool, error) {
+	exists, _, err := lookup(ctx, pc, path)
+	return exists, err
+}
+
+// lookup checks the path using the most capable method the PathChecker has,
+// also returning the object's metadata if it's a Statter
+func lookup(ctx context.Context, pc PathChecker, path string) (bool, S3ObjectInfo, error) {
+	switch c := pc.(type) {
+	case Statter:
+		return lookupContext(ctx, func() (bool, S3ObjectInfo, error) {
+			info, err := c.Stat(path)
+			if err != nil {
+				if isNotFound(err) {
+					return false, S3ObjectInfo{}, nil
+				}
+				return false, S3ObjectInfo{}, err
+			}
+			return true, info, nil
+		})
+	case ErrorPathChecker:
+		return lookupContext(ctx, func() (bool, S3ObjectInfo, error) {
+			exists, err := c.FileExistsErr(path)
+			return exists, S3ObjectInfo{}, err
+		})
+	case ContextPathChecker:
+		return c.FileExistsContext(ctx, path), S3ObjectInfo{}, nil
+	default:
+		return pc.FileExists(path), S3ObjectInfo{}, nil
+	}
 }
 
 // GetDataFilename returns the s3 filepath associated with an S3File
 // 3useful for redshift COPY commands, amongst other things
 func (f *S3File) GetDataFilename() string {
-	return fmt.Sprintf("s3://%s/%s/%s_%s_%s.%s", f.Bucket.Name, f.Subfolder, f.Schema, f.Table, f.DataDate.Format(time.RFC3339), f.Suffix)
+	return f.DataFilenameForSuffix(f.Suffix)
+}
+
+// DataFilenameForSuffix returns the s3 filepath the S3File would have with the given suffix,
+// without changing its Suffix. The suffix can have a leading dot or be empty, like Suffix.
+func (f *S3File) DataFilenameForSuffix(suffix string) string {
+	if f.Part != "" {
+		return s3Path(f.Bucket, f.Subfolder, f.Part) + formatSuffix(suffix)
+	}
+	return f.GetDataPrefix() + formatSuffix(suffix)
+}
+
+// GetDataPrefix returns the data filename without its suffix, s3://bucket/subfolder/schema_table_date.
+// A COPY from a prefix loads every object whose key starts with it, so this loads data written
+// in several parts (schema_table_date.0000_part_00, ...) without needing a manifest.
+func (f *S3File) GetDataPrefix() string {
+	return s3Path(f.Bucket, f.Subfolder, f.joinParts(f.filenameParts()...))
+}
+
+// filenameParts are what the data filename is made of, before its suffix
+func (f *S3File) filenameParts() []string {
+	if f.withoutSchemaPrefix {
+		return []string{f.Table, f.formattedDate()}
+	}
+	return []string{f.Schema, f.Table, f.formattedDate()}
+}
+
+// joinParts joins the parts of a filename with the S3File's separator
+func (f *S3File) joinParts(parts ...string) string {
+	sep := f.separator
+	if sep == "" {
+		sep = DefaultSeparator
+	}
+	return strings.Join(parts, sep)
+}
+
+// GetDataGlob returns a glob matching every data file for the S3File with the given suffix,
+// the data prefix followed by a "*" and the suffix, e.g. s3://bucket/subfolder/schema_table_date*.json.gz.
+// COPY doesn't take wildcards, so this is for filtering listings or tools like `aws s3 cp --include`;
+// use GetDataPrefix to COPY.
+func (f *S3File) GetDataGlob(suffix string) string {
+	return f.GetDataPrefix() + "*" + formatSuffix(suffix)
+}
+
+// String returns the data filename and the suffix it was found with, for logging.
+// The suffix is printed separately so an empty suffix is still visible.
+func (f *S3File) String() string {
+	return fmt.Sprintf("%s (suffix %q)", f.GetDataFilename(), f.Suffix)
+}
+
+// Equal reports whether two S3Files point at the same data: the same bucket name,
+// schema, table, suffix and date. Dates are compared with time.Equal, so the same
+// instant in different locations is equal. Derived fields like Subfolder and ConfFile
+// and the found Object info are ignored.
+func (f *S3File) Equal(other *S3File) bool {
+	if f == nil || other == nil {
+		return f == other
+	}
+	return f.Bucket.Name == other.Bucket.Name &&
+		f.Schema == other.Schema &&
+		f.Table == other.Table &&
+		normalizeSuffix(f.Suffix) == normalizeSuffix(other.Suffix) &&
+		f.Part == other.Part &&
+		f.DataDate.Equal(other.DataDate)
+}
+
+// GetConfigFilename returns the s3 filepath of the generated config file for an S3File.
+// This is what CreateS3File uses for ConfFile when no config file is supplied.
+func (f *S3File) GetConfigFilename() string {
+	if f.configNamer != nil {
+		return s3Path(f.Bucket, f.Subfolder, f.configNamer(f.Schema, f.Table, f.DataDate))
+	}
+	return f.configFilename(configExtensions[0])
+}
+
+func (f *S3File) configFilename(ext string) string {
+	return s3Path(f.Bucket, f.Subfolder, f.joinParts(append([]string{"config"}, f.filenameParts()...)...)+"."+ext)
+}
+
+// tableConfigFilename is the config file for every date of the table, in the table's folder
+// and named like configFilename without the date
+func (f *S3File) tableConfigFilename(ext string) string {
+	parts := f.filenameParts()
+	name := f.joinParts(append([]string{"config"}, parts[:len(parts)-1]...)...)
+	return s3Path(f.Bucket, f.Schema, f.Table, name+"."+ext)
+}
+
+// s3Path joins the bucket's Prefix and the key parts with path.Join and puts the scheme://bucket/
+// prefix in front, so an empty or slash-terminated prefix or subfolder doesn't leave a double slash in the key
+func s3Path(bucket S3Bucket, keyParts ...string) string {
+	key := path.Join(append([]string{bucket.Prefix}, keyParts...)...)
+	return bucket.scheme() + "://" + bucket.Name + "/" + strings.TrimPrefix(key, "/")
+}
+
+// splitScheme splits a s3:// or gs:// path into its scheme and the rest of the path
+func splitScheme(path string) (string, string, bool) {
+	for _, scheme := range []string{SchemeS3, SchemeGCS} {
+		if prefix := scheme + "://"; strings.HasPrefix(path, prefix) {
+			return scheme, strings.TrimPrefix(path, prefix), true
+		}
+	}
+	return "", path, false
+}
+
+// formattedDate returns DataDate as it appears in filenames, which is always in UTC
+func (f *S3File) formattedDate() string {
+	date := f.DataDate.UTC()
+	switch f.DateLayout {
+	case "":
+		return date.Format(time.RFC3339)
+	case DateLayoutEpochSeconds:
+		return strconv.FormatInt(date.Unix(), 10)
+	case DateLayoutEpochMillis:
+		return strconv.FormatInt(date.UnixNano()/int64(time.Millisecond), 10)
+	default:
+		return date.Format(f.DateLayout)
+	}
+}
+
+func existsOrFalse(exists bool, err error) bool {
+	return exists && err == nil
+}
+
+// findConfigFile points ConfFile at whichever generated config file exists, trying each
+// config extension in order. If none of them exist ConfFile is left as it was.
+// Supplied config files and ones named by WithConfigNamer are used as is.
+func findConfigFile(ctx context.Context, pc PathChecker, f *S3File, suppliedConf string) {
+	// a custom config filename is used as is, there are no other extensions to try
+	if suppliedConf != "" || f.configNamer != nil {
+		return
+	}
+	for _, confFile := range f.candidateConfigPaths(suppliedConf) {
+		// a config file we can't look up is treated like a missing one,
+		// the error will come up again when it's read
+		if existsOrFalse(fileExists(ctx, pc, confFile)) {
+			f.ConfFile = confFile
+			return
+		}
+	}
+}
+
+// candidateConfigPaths returns the config files findConfigFile looks for, in order
+func (f *S3File) candidateConfigPaths(suppliedConf string) []string {
+	if suppliedConf != "" || f.configNamer != nil {
+		return []string{f.ConfFile}
+	}
+	paths := make([]string, 0, len(configExtensions))
+	for _, ext := range configExtensions {
+		paths = append(paths, f.configFilename(ext))
+	}
+	return paths
+}
+
+// checkConfigExists returns an error naming the data file and the config files tried if the config file doesn't exist
+func checkConfigExists(ctx context.Context, pc PathChecker, f *S3File, suppliedConf string) error {
+	exists, err := fileExists(ctx, pc, f.ConfFile)
+	if err != nil {
+		return fmt.Errorf("error looking for config file %s for s3 file %s: %w", f.ConfFile, f.GetDataFilename(), err)
+	}
+	if !exists {
+		return fmt.Errorf("%w for s3 file %s, tried: %s", ErrConfigNotFound, f.GetDataFilename(),
+			strings.Join(f.candidateConfigPaths(suppliedConf), ", "))
+	}
+	return nil
+}
+
+// Format returns the format of the data file based on its suffix
+func (f *S3File) Format() FileFormat {
+	switch strings.Split(strings.ToLower(normalizeSuffix(f.Suffix)), ".")[0] {
+	case "manifest":
+		return FormatUnknown
+	case "json":
+		return FormatJSON
+	case "parquet":
+		return FormatParquet
+	default:
+		// csv files are either not suffixed or just a compression suffix like ".gz"
+		return FormatCSV
+	}
+}
+
+// isManifest returns whether the suffix is a manifest's, in any case
+func isManifest(suffix string) bool {
+	return strings.EqualFold(suffix, "manifest")
+}
+
+// withUppercaseSuffixes returns the suffixes with each one followed by its uppercase version,
+// if that's different
+func withUppercaseSuffixes(suffixes []string) []string {
+	var all []string
+	for _, suffix := range suffixes {
+		all = append(all, suffix)
+		if upper := strings.ToUpper(suffix); upper != suffix {
+			all = append(all, upper)
+		}
+	}
+	return all
+}
+
+// normalizeSuffix strips a leading dot so that both ".gz" and "gz" end up as a single "." in the filename
+func normalizeSuffix(suffix string) string {
+	return strings.TrimPrefix(suffix, ".")
+}
+
+// formatSuffix returns the suffix as it appears at the end of a filename, including the dot.
+// An empty suffix (plain csv) has no dot at all.
+func formatSuffix(suffix string) string {
+	if suffix = normalizeSuffix(suffix); suffix == "" {
+		return ""
+	}
+	return "." + suffix
+}
+
+// canonicalSuffix maps a suffix read back out of a filename onto the matching entry
+// of the extended suffix list (e.g. "gz" -> ".gz"), so parsed files match discovered ones
+func canonicalSuffix(suffix string) string {
+	for _, s := range extendedSuffixes {
+		if normalizeSuffix(s) == suffix {
+			return s
+		}
+	}
+	return suffix
+}
+
+// ParseDataFilename is the inverse of GetDataFilename: it takes a full
+// s3://bucket/subfolder/schema_table_date.suffix path (or gs://) and rebuilds the S3File it came from.
+// Since the config file isn't part of the path, ConfFile is set to the generated default,
+// and the bucket Region and RedshiftRoleARN are left empty. The path must start at the
+// bucket root, paths under a bucket Prefix can't be told apart from other subfolders.
+// Filenames without the schema, table_date.suffix, are parsed as if built WithoutSchemaPrefix.
+func ParseDataFilename(path string) (*S3File, error) {
+	return ParseDataFilenameWithSeparator(path, DefaultSeparator)
+}
+
+// ParseDataFilenameWithSeparator is ParseDataFilename for files named with a separator
+// other than DefaultSeparator, see WithSeparator
+func ParseDataFilenameWithSeparator(path, separator string) (*S3File, error) {
+	if err := validateSeparator(separator); err != nil {
+		return nil, err
+	}
+	return ParseDataFilenameWithOptions(path, WithSeparator(separator))
+}
+
+// ParseDataFilenameWithOptions is ParseDataFilename for files built with the given options.
+// WithSeparator, WithDateLayout and WithPartitionKeys change how the path is parsed, e.g.
+// WithDateLayout(DateLayoutEpochSeconds) for schema_table_1447196400.json files.
+// Options that change the number of folders, like WithFlatLayout, aren't supported.
+func ParseDataFilenameWithOptions(path string, opts ...S3FileOption) (*S3File, error) {
+	template := newS3File(S3Bucket{}, "", "", opts...)
+	separator := DefaultSeparator
+	if template.separator != "" {
+		if err := validateSeparator(template.separator); err != nil {
+			return nil, err
+		}
+		separator = template.separator
+	}
+	scheme, rest, ok := splitScheme(path)
+	if !ok {
+		return nil, fmt.Errorf("data filename must start with s3:// or gs://: %s", path)
+	}
+	if scheme == SchemeS3 {
+		// so that s3 files look just like they did before buckets had a scheme
+		scheme = ""
+	}
+	parts := strings.Split(rest, "/")
+	// bucket, schema, table, year, month, day, filename
+	if len(parts) != 7 {
+		return nil, fmt.Errorf("data filename does not match the expected layout: %s", path)
+	}
+	bucketName, schema, table, filename := parts[0], parts[1], parts[2], parts[6]
+	if bucketName == "" || schema == "" || table == "" {
+		return nil, fmt.Errorf("data filename is missing bucket, schema or table: %s", path)
+	}
+	if err := validateNames(schema, table); err != nil {
+		return nil, err
+	}
+
+	prefix := schema + separator + table + separator
+	opts = append([]S3FileOption{}, opts...)
+	if !strings.HasPrefix(filename, prefix) {
+		if !strings.HasPrefix(filename, table+separator) {
+			return nil, fmt.Errorf("data filename %s does not start with %s or %s", filename, prefix, table+separator)
+		}
+		prefix = table + separator
+		opts = append(opts, WithoutSchemaPrefix())
+	}
+	date, suffix, err := parseFilenameDate(strings.TrimPrefix(filename, prefix), template.DateLayout)
+	if err != nil {
+		return nil, fmt.Errorf("%s in data filename: %s", err, filename)
+	}
+
+	f := newS3File(S3Bucket{Name: bucketName, Scheme: scheme}, schema, table,
+		append(opts, WithDate(date), WithSuffix(canonicalSuffix(suffix)))...)
+	if dir := strings.Join(parts[1:6], "/"); dir != f.Subfolder {
+		return nil, fmt.Errorf("subfolder %s does not match the expected subfolder %s", dir, f.Subfolder)
+	}
+	return f, nil
+}
+
+// parseFilenameDate splits the rest of a data filename after the schema and table into the date,
+// formatted with the date layout, and the suffix
+func parseFilenameDate(rest, dateLayout string) (time.Time, string, error) {
+	regex := dateRegex
+	switch dateLayout {
+	case "":
+		regex = s3Regex
+	case DateLayoutEpochSeconds, DateLayoutEpochMillis:
+		regex = epochRegex
+	}
+	matches := regex.FindStringSubmatch(rest)
+	if matches == nil {
+		return time.Time{}, "", fmt.Errorf("could not find date and suffix")
+	}
+	var date time.Time
+	switch dateLayout {
+	case "":
+		parsed, err := time.Parse(time.RFC3339, matches[1])
+		if err != nil {
+			return time.Time{}, "", fmt.Errorf("could not parse date: %s", err)
+		}
+		date = parsed
+	case DateLayoutEpochSeconds, DateLayoutEpochMillis:
+		epoch, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return time.Time{}, "", fmt.Errorf("could not parse date: %s", err)
+		}
+		if dateLayout == DateLayoutEpochSeconds {
+			date = time.Unix(epoch, 0)
+		} else {
+			date = time.Unix(0, epoch*int64(time.Millisecond))
+		}
+	default:
+		parsed, err := time.Parse(dateLayout, matches[1])
+		if err != nil {
+			return time.Time{}, "", fmt.Errorf("could not parse date: %s", err)
+		}
+		date = parsed
+	}
+	return date.UTC(), matches[2], nil
+}
+
+// buildS3File fills in the subfolder and config file for an S3File with the given suffix
+func buildS3File(bucket S3Bucket, schema, table, suppliedConf string, date time.Time, suffix string) *S3File {
+	return buildS3FileWithLayout(bucket, schema, table, suppliedConf, date, suffix, "")
+}
+
+func buildS3FileWithLayout(bucket S3Bucket, schema, table, suppliedConf string, date time.Time,
+	suffix, dateLayout string) *S3File {
+	return newS3File(bucket, schema, table, WithDate(date), WithConfFile(suppliedConf), WithSuffix(suffix),
+		WithDateLayout(dateLayout))
 }
 
 // CreateS3File creates an S3File object with either a supplied config
-// file or the function generates a config file name
+// file or the function generates a config file name.
+// The date is converted to UTC, so DataDate, the partition folders and the
+// date in the filenames are all in UTC whatever location the date was given in.
 func CreateS3File(pc PathChecker, bucket S3Bucket, schema, table, suppliedConf string, date time.Time) (*S3File, error) {
-	// set configuration location
-	formattedDate := date.Format(time.RFC3339)
-	subfolder := fmt.Sprintf("%s/%s/_data_timestamp_year=%02d/_data_timestamp_month=%02d/_data_timestamp_day=%02d",
-		schema, table, date.Year(), int(date.Month()), date.Day())
-	confFile := fmt.Sprintf("s3://%s/%s/config_%s_%s_%s.yml", bucket.Name, subfolder, schema, table, formattedDate)
-	if suppliedConf != "" {
-		confFile = suppliedConf
+	return CreateS3FileContext(context.Background(), pc, bucket, schema, table, suppliedConf, date)
+}
+
+// CreateS3FileContext is CreateS3File, but stops looking for further suffixes
+// and returns the context's error once the context is done
+func CreateS3FileContext(ctx context.Context, pc PathChecker, bucket S3Bucket, schema, table, suppliedConf string,
+	date time.Time) (*S3File, error) {
+	return createS3File(ctx, pc, bucket, schema, table, suppliedConf, date, defaultSuffixes, nil)
+}
+
+// CreateS3FileWithSuffixes is CreateS3File, but only looks for the given suffixes.
+// Order matters: the suffixes are tried in the order given and the first one that exists wins.
+// Use "" to look for a plain csv file with no suffix.
+func CreateS3FileWithSuffixes(pc PathChecker, bucket S3Bucket, schema, table, suppliedConf string, date time.Time,
+	suffixes []string) (*S3File, error) {
+	return createS3File(context.Background(), pc, bucket, schema, table, suppliedConf, date, suffixes, nil)
+}
+
+// CreateS3FileWithDateLayout is CreateS3File for files whose names use a date layout
+// other than RFC3339, e.g. "20060102" or DateLayoutEpochSeconds.
+// The partition folders always use the numeric year, month and day.
+func CreateS3FileWithDateLayout(pc PathChecker, bucket S3Bucket, schema, table, suppliedConf string, date time.Time,
+	dateLayout string) (*S3File, error) {
+	return createS3File(context.Background(), pc, bucket, schema, table, suppliedConf, date, defaultSuffixes,
+		[]S3FileOption{WithDateLayout(dateLayout)})
+}
+
+// CreateS3FileWithOptions is CreateS3FileContext, but builds the paths it looks for with the given
+// options, e.g. WithFlatLayout or WithDateLayout. Every default suffix (or extended one, with
+// WithExtendedSuffixes) is still tried in order, so WithSuffix has no effect.
+func CreateS3FileWithOptions(ctx context.Context, pc PathChecker, bucket S3Bucket, schema, table, suppliedConf string,
+	date time.Time, opts ...S3FileOption) (*S3File, error) {
+	return createS3File(ctx, pc, bucket, schema, table, suppliedConf, date, defaultSuffixes, opts)
+}
+
+// DefaultSuffixes returns a copy of the suffixes CreateS3File looks for, in the order it tries them.
+// Useful as a starting point for CreateS3FileWithSuffixes.
+func DefaultSuffixes() []string {
+	return append([]string{}, defaultSuffixes...)
+}
+
+// ExtendedSuffixes returns a copy of the suffixes WithExtendedSuffixes looks for, in the order
+// they're tried: the default ones plus the bzip2, zstd and parquet ones. Use them with CreateS3FileWithSuffixes
+// when the COPY run on the file knows its format, e.g. one from CopyCommand.
+func ExtendedSuffixes() []string {
+	return append([]string{}, extendedSuffixes...)
+}
+
+func createS3File(ctx context.Context, pc PathChecker, bucket S3Bucket, schema, table, suppliedConf string,
+	date time.Time, suffixes []string, opts []S3FileOption) (*S3File, error) {
+	if err := validateNames(schema, table); err != nil {
+		return nil, err
 	}
-	// Try to find manifest or data files out of the following patterns, in order
-	// we try to get in order as otherwise
-	for _, suffix := range []string{
-		"manifest", // 1) manifest file
-		"json.gz",  // 2) gzipped json file
-		"json",     // 3) json file
-		".gz",      // 4) gzipped csv file (.gz)
-		""} {       // 5) csv file (no suffix when UNLOADed :-/)
-		inputFile := S3File{bucket, schema, table, suffix, date, subfolder, confFile}
-		if pc.FileExists(inputFile.GetDataFilename()) {
-			return &inputFile, nil
+	template := candidateFile(bucket, schema, table, suppliedConf, date, "", opts)
+	if template.separator != "" {
+		if err := validateSeparator(template.separator); err != nil {
+			return nil, err
+		}
+	}
+	if template.extendedSuffixes {
+		suffixes = extendedSuffixes
+	}
+	if template.uppercaseSuffixes {
+		suffixes = withUppercaseSuffixes(suffixes)
+	}
+	attempts := make([]LookupAttempt, 0, len(suffixes))
+	for i, suffix := range suffixes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
 		}
+		inputFile := candidateFile(bucket, schema, table, suppliedConf, date, suffix, opts)
+		dataPath := inputFile.GetDataFilename()
+		exists, info, err := observedLookup(ctx, pc, dataPath, suffix)
+		attempts = append(attempts, LookupAttempt{Suffix: suffix, Path: dataPath, Found: exists, Err: err})
+		if err != nil {
+			currentLogger().Log("s3file-lookup-error", map[string]interface{}{"path": dataPath, "suffix": suffix, "error": err.Error()})
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, &LookupError{Attempts: attempts, err: fmt.Errorf("error looking for s3 file %s: %w", dataPath, err)}
+		}
+		currentLogger().Log("s3file-lookup", map[string]interface{}{"path": dataPath, "suffix": suffix, "found": exists})
+		if exists {
+			inputFile.Object = info
+			findConfigFile(ctx, pc, inputFile, suppliedConf)
+			if inputFile.requireConfig {
+				if err := checkConfigExists(ctx, pc, inputFile, suppliedConf); err != nil {
+					return nil, err
+				}
+			}
+			if inputFile.ambiguityCheck && inputFile.IsManifest() {
+				if err := checkUnambiguous(ctx, pc, inputFile, suffixes[i+1:], opts); err != nil {
+					return nil, err
+				}
+			}
+			currentLogger().Log("s3file-found", map[string]interface{}{"path": dataPath})
+			return inputFile, nil
+		}
+	}
+	currentLogger().Log("s3file-not-found", map[string]interface{}{
+		"candidates": candidateDataPaths(bucket, schema, table, date, suffixes, opts),
+	})
+	return nil, &LookupError{Attempts: attempts, err: notFoundError(bucket, schema, table, date, suffixes, opts)}
+}
+
+// checkUnambiguous returns an AmbiguousFileError if any of the other suffixes exist next to the manifest
+func checkUnambiguous(ctx context.Context, pc PathChecker, manifest *S3File, suffixes []string, opts []S3FileOption) error {
+	var dataFiles []string
+	for _, suffix := range suffixes {
+		if isManifest(suffix) {
+			continue
+		}
+		other := candidateFile(manifest.Bucket, manifest.Schema, manifest.Table, "", manifest.DataDate, suffix, opts)
+		exists, err := fileExists(ctx, pc, other.GetDataFilename())
+		if err != nil {
+			return fmt.Errorf("error looking for s3 file %s: %w", other.GetDataFilename(), err)
+		}
+		if exists {
+			dataFiles = append(dataFiles, other.GetDataFilename())
+		}
+	}
+	if len(dataFiles) > 0 {
+		return &AmbiguousFileError{Manifest: manifest.GetDataFilename(), DataFiles: dataFiles}
+	}
+	return nil
+}
+
+// WaitForFile looks for the data file every interval until it shows up, or returns an error naming
+// what it was waiting for, wrapping the context's error, once the context is done. A lookup that
+// fails for any other reason than the file not being there yet (e.g. access denied) is returned
+// straight away, since waiting won't fix it. The interval must be positive.
+func WaitForFile(ctx context.Context, pc PathChecker, bucket S3Bucket, schema, table string, date time.Time,
+	interval time.Duration) (*S3File, error) {
+	// a bad name will never show up, so don't wait for it
+	if err := validateNames(schema, table); err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("interval must be positive, got %s", interval)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		f, err := CreateS3FileContext(ctx, pc, bucket, schema, table, "", date)
+		if err == nil {
+			return f, nil
+		}
+		if ctx.Err() == nil {
+			if !errors.Is(err, ErrFileNotFound) {
+				return nil, err
+			}
+			select {
+			case <-ticker.C:
+				continue
+			case <-ctx.Done():
+			}
+		}
+		return nil, fmt.Errorf("stopped waiting for s3 file at: bucket: %s schema: %s, table: %s date: %s: %w",
+			bucket.Name, schema, table, date.Format(time.RFC3339), ctx.Err())
+	}
+}
+
+// FindLatestFile looks for data for the table on startDate, then on each day before it,
+// going back at most maxDaysBack days, and returns the first file it finds.
+// The time of day of startDate is kept for every date checked.
+func FindLatestFile(pc PathChecker, bucket S3Bucket, schema, table string, startDate time.Time,
+	maxDaysBack int) (*S3File, error) {
+	return FindLatestFileContext(context.Background(), pc, bucket, schema, table, startDate, maxDaysBack, 1)
+}
+
+// FindLatestFileContext is FindLatestFile, but stops looking once the context is done, and can
+// scan in strides of more than one day. With a stride it checks startDate, then every stride
+// days before it, and once it finds a file checks the days after that one it skipped, newest
+// first. That saves lookups for tables that stopped being written to a while ago, but it can
+// miss data that was only written on the days skipped before the first file found.
+// A failed lookup is returned rather than treated as a missing file. If nothing is found
+// it returns a NoRecentFileError, which matches ErrNoRecentFile.
+func FindLatestFileContext(ctx context.Context, pc PathChecker, bucket S3Bucket, schema, table string,
+	startDate time.Time, maxDaysBack, stride int) (*S3File, error) {
+	if err := validateNames(schema, table); err != nil {
+		return nil, err
+	}
+	if stride < 1 {
+		stride = 1
+	}
+	find := func(daysBack int) (*S3File, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("stopped looking for s3 file for bucket: %s schema: %s, table: %s at %s: %w",
+				bucket.Name, schema, table, startDate.AddDate(0, 0, -daysBack).Format(time.RFC3339), err)
+		}
+		f, err := CreateS3FileContext(ctx, pc, bucket, schema, table, "", startDate.AddDate(0, 0, -daysBack))
+		if errors.Is(err, ErrFileNotFound) {
+			return nil, nil
+		}
+		return f, err
+	}
+	for prev, daysBack := -1, 0; prev < maxDaysBack; prev, daysBack = daysBack, daysBack+stride {
+		if daysBack > maxDaysBack {
+			// the last stride goes past maxDaysBack, so check maxDaysBack itself
+			daysBack = maxDaysBack
+		}
+		f, err := find(daysBack)
+		if err != nil {
+			return nil, err
+		}
+		if f == nil {
+			continue
+		}
+		// the days skipped since the previous check are all newer than the file found
+		for skipped := prev + 1; skipped < daysBack; skipped++ {
+			newer, err := find(skipped)
+			if err != nil {
+				return nil, err
+			}
+			if newer != nil {
+				return newer, nil
+			}
+		}
+		return f, nil
+	}
+	return nil, &NoRecentFileError{Bucket: bucket.Name, Schema: schema, Table: table,
+		From: startDate.AddDate(0, 0, -maxDaysBack), To: startDate}
+}
+
+// ListDatesInRange returns each day from from to to, inclusive, that has a data file for the
+// table, in ascending order. The time of day of from is kept for every date checked.
+// It doesn't look for config files, and stops at the first data file found for each day.
+func ListDatesInRange(pc PathChecker, bucket S3Bucket, schema, table string, from, to time.Time) ([]time.Time, error) {
+	if err := validateNames(schema, table); err != nil {
+		return nil, err
+	}
+	var dates []time.Time
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		exists, err := dataFileExists(pc, bucket, schema, table, date)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			dates = append(dates, date)
+		}
+	}
+	return dates, nil
+}
+
+// dataFileExists returns whether a data file with any of the default suffixes exists
+func dataFileExists(pc PathChecker, bucket S3Bucket, schema, table string, date time.Time) (bool, error) {
+	for _, suffix := range defaultSuffixes {
+		dataPath := buildS3File(bucket, schema, table, "", date, suffix).GetDataFilename()
+		exists, _, err := observedLookup(context.Background(), pc, dataPath, suffix)
+		if err != nil {
+			return false, fmt.Errorf("error looking for s3 file %s: %w", dataPath, err)
+		}
+		if exists {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// FindS3Files returns an S3File for every default suffix that exists for the table and date,
+// in the order CreateS3File would try them. More than one result means the data is ambiguous
+// (e.g. both a json.gz and a json file were uploaded), which callers should treat as an error.
+func FindS3Files(pc PathChecker, bucket S3Bucket, schema, table string, date time.Time) ([]*S3File, error) {
+	if err := validateNames(schema, table); err != nil {
+		return nil, err
+	}
+	var found []*S3File
+	attempts := make([]LookupAttempt, 0, len(defaultSuffixes))
+	for _, suffix := range defaultSuffixes {
+		inputFile := buildS3File(bucket, schema, table, "", date, suffix)
+		exists, info, err := observedLookup(context.Background(), pc, inputFile.GetDataFilename(), suffix)
+		attempts = append(attempts, LookupAttempt{Suffix: suffix, Path: inputFile.GetDataFilename(), Found: exists, Err: err})
+		if err != nil {
+			return nil, &LookupError{Attempts: attempts,
+				err: fmt.Errorf("error looking for s3 file %s: %w", inputFile.GetDataFilename(), err)}
+		}
+		if exists {
+			inputFile.Object = info
+			findConfigFile(context.Background(), pc, inputFile, "")
+			found = append(found, inputFile)
+		}
+	}
+	if len(found) == 0 {
+		return nil, &LookupError{Attempts: attempts, err: notFoundError(bucket, schema, table, date, defaultSuffixes, nil)}
+	}
+	return found, nil
+}
+
+// CandidateDataPaths returns the full s3 paths CreateS3File looks for, in the order
+// it tries them. It doesn't check whether any of them exist.
+func CandidateDataPaths(bucket S3Bucket, schema, table string, date time.Time) []string {
+	return candidateDataPaths(bucket, schema, table, date, defaultSuffixes, nil)
+}
+
+// CandidateFilenames returns the full s3 path of f for each default suffix (or each extended one,
+// if f has WithExtendedSuffixes), in the order CreateS3File tries them, whatever f.Suffix is.
+// Unlike CandidateDataPaths it keeps f's own subfolder and filename options.
+// It doesn't check whether any of them exist.
+func (f *S3File) CandidateFilenames() []string {
+	suffixes := defaultSuffixes
+	if f.extendedSuffixes {
+		suffixes = extendedSuffixes
+	}
+	c := *f
+	c.Part = ""
+	paths := make([]string, 0, len(suffixes))
+	for _, suffix := range suffixes {
+		paths = append(paths, c.DataFilenameForSuffix(suffix))
+	}
+	return paths
+}
+
+// CandidateConfigPaths returns the full s3 paths CreateS3File looks for the config file at when
+// none is supplied, in the order it tries them: the .yml one, then the .yaml one.
+// It doesn't check whether any of them exist.
+func CandidateConfigPaths(bucket S3Bucket, schema, table string, date time.Time) []string {
+	return candidateFile(bucket, schema, table, "", date, "", nil).candidateConfigPaths("")
+}
+
+// FindConfigFile returns the first of the table's config files that exists: the ones for the date
+// that CandidateConfigPaths returns, then the table level ones for teams that keep one config for
+// every date, s3://bucket/schema/table/config_schema_table.yml and then .yaml. If none of them
+// exist the error matches ErrConfigNotFound and lists every path tried.
+// The options name the files like they do for CreateS3FileWithOptions, e.g. WithSeparator("-")
+// looks for config-schema-table.yml. With WithConfigNamer only the namer's file is looked for.
+func FindConfigFile(pc PathChecker, bucket S3Bucket, schema, table string, date time.Time,
+	opts ...S3FileOption) (string, error) {
+	if err := validateNames(schema, table); err != nil {
+		return "", err
+	}
+	f := candidateFile(bucket, schema, table, "", date, "", opts)
+	if f.separator != "" {
+		if err := validateSeparator(f.separator); err != nil {
+			return "", err
+		}
+	}
+	candidates := f.candidateConfigPaths("")
+	if f.configNamer == nil {
+		for _, ext := range configExtensions {
+			candidates = append(candidates, f.tableConfigFilename(ext))
+		}
+	}
+	for _, confFile := range candidates {
+		exists, err := fileExists(context.Background(), pc, confFile)
+		if err != nil {
+			return "", fmt.Errorf("error looking for config file %s: %w", confFile, err)
+		}
+		if exists {
+			return confFile, nil
+		}
+	}
+	return "", fmt.Errorf("%w for schema: %s, table: %s date: %s, tried: %s", ErrConfigNotFound, schema, table,
+		date.UTC().Format(time.RFC3339), strings.Join(candidates, ", "))
+}
+
+// ResolveExpectedFile returns the S3File CreateS3File would return if the data file with
+// the given suffix were the one found, without making any S3 calls. When conf is empty the
+// config file is the default .yml one, since no other config extensions are looked for.
+func ResolveExpectedFile(bucket S3Bucket, schema, table, conf string, date time.Time, suffix string) *S3File {
+	return candidateFile(bucket, schema, table, conf, date, suffix, nil)
+}
+
+// candidateFile builds the S3File looked for with the given suffix. The options are applied
+// after the date and config file, and before the suffix so it can't be overridden.
+func candidateFile(bucket S3Bucket, schema, table, suppliedConf string, date time.Time, suffix string,
+	opts []S3FileOption) *S3File {
+	all := make([]S3FileOption, 0, len(opts)+3)
+	all = append(all, WithDate(date), WithConfFile(suppliedConf))
+	all = append(all, opts...)
+	all = append(all, WithSuffix(suffix))
+	return newS3File(bucket, schema, table, all...)
+}
+
+func candidateDataPaths(bucket S3Bucket, schema, table string, date time.Time, suffixes []string,
+	opts []S3FileOption) []string {
+	paths := make([]string, 0, len(suffixes))
+	for _, suffix := range suffixes {
+		paths = append(paths, candidateFile(bucket, schema, table, "", date, suffix, opts).GetDataFilename())
+	}
+	return paths
+}
+
+func notFoundError(bucket S3Bucket, schema, table string, date time.Time, suffixes []string, opts []S3FileOption) error {
+	return &FileNotFoundError{
+		Bucket:     bucket.Name,
+		Schema:     schema,
+		Table:      table,
+		Date:       date,
+		Candidates: candidateDataPaths(bucket, schema, table, date, suffixes, opts),
 	}
-	return nil, fmt.Errorf("s3 file not found at: bucket: %s schema: %s, table: %s date: %s",
-		bucket.Name, schema, table, formattedDate)
 }
@@ -1,11 +1,18 @@
 package s3filepath
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,7 +21,7 @@ var (
 )
 
 func getTestFileWithResults(b, s, t, r, arn, subfolder, confFile, suf string, date time.Time) S3File {
-	bucket := S3Bucket{b, r, arn}
+	bucket := S3Bucket{Name: b, Region: r, RedshiftRoleARN: arn}
 	s3File := S3File{
 		Bucket:    bucket,
 		Schema:    s,
@@ -36,6 +43,32 @@ func (mp MockPathChecker) FileExists(path string) bool {
 	return mp.ExistingPaths[path]
 }
 
+// MockLister lists the paths that start with the prefix
+type MockLister struct {
+	Paths []string
+	Err   error
+}
+
+func (ml MockLister) List(prefix string) ([]string, error) {
+	if ml.Err != nil {
+		return nil, ml.Err
+	}
+	var found []string
+	for _, p := range ml.Paths {
+		if strings.HasPrefix(p, prefix) {
+			found = append(found, p)
+		}
+	}
+	return found, nil
+}
+
+// newFailingPathChecker returns an InMemoryPathChecker where nothing exists and the first checks fail with errs
+func newFailingPathChecker(errs ...error) *InMemoryPathChecker {
+	pc := NewInMemoryPathChecker()
+	pc.Fail(errs...)
+	return pc
+}
+
 func TestCreateS3File(t *testing.T) {
 	bucket, schema, table, region, redshiftRoleARN := "b", "s", "t", "r", "arn"
 	expFolder := fmt.Sprintf("%s/%s/_data_timestamp_year=%02d/_data_timestamp_month=%02d/_data_timestamp_day=%02d",
@@ -51,7 +84,9 @@ func TestCreateS3File(t *testing.T) {
 	// test completely non-existent file
 	expFile := getTestFileWithResults(bucket, schema, table, region, redshiftRoleARN, expFolder, expConf, "json.gz", expectedDate)
 	returnedFile, err := CreateS3File(MockPathChecker{}, expFile.Bucket, schema, "bad_table", "", expectedDate)
-	assert.Equal(t, errors.New("s3 file not found at: bucket: b schema: s, table: bad_table date: 2015-11-10T23:00:00Z"), err)
+	assert.EqualError(t, err, "s3 file not found at: bucket: b schema: s, table: bad_table date: 2015-11-10T23:00:00Z, tried: "+
+		strings.Join(CandidateDataPaths(expFile.Bucket, schema, "bad_table", expectedDate), ", "))
+	assert.True(t, errors.Is(err, ErrFileNotFound))
 
 	// test generated json gzip conf file
 	expFile = getTestFileWithResults(bucket, schema, table, region, redshiftRoleARN, expFolder, expConf, "json.gz", expectedDate)
@@ -74,6 +109,15 @@ func TestCreateS3File(t *testing.T) {
 	assert.Equal(t, nil, err)
 	assert.Equal(t, expFile, *returnedFile)
 
+	// test plain csv file with no suffix
+	expFile = getTestFileWithResults(bucket, schema, table, region, redshiftRoleARN, expFolder, expConf, "", expectedDate)
+	testFiles = map[string]bool{
+		csvPath: true,
+	}
+	returnedFile, err = CreateS3File(MockPathChecker{testFiles}, expFile.Bucket, schema, table, "", expectedDate)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, expFile, *returnedFile)
+
 	// test generated manifest conf file
 	expFile = getTestFileWithResults(bucket, schema, table, region, redshiftRoleARN, expFolder, expConf, "manifest", expectedDate)
 	testFiles = map[string]bool{
@@ -95,3 +139,976 @@ func TestCreateS3File(t *testing.T) {
 	assert.Equal(t, nil, err)
 	assert.Equal(t, expFile, *returnedFile)
 }
+
+func TestCreateS3FileParquet(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	prefix := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z"
+	ctx := context.Background()
+
+	// parquet isn't looked for by default
+	pc := MockPathChecker{map[string]bool{prefix + ".parquet": true}}
+	_, err := CreateS3File(pc, bucket, "s", "t", "", expectedDate)
+	assert.True(t, errors.Is(err, ErrFileNotFound))
+
+	f, err := CreateS3FileWithOptions(ctx, pc, bucket, "s", "t", "", expectedDate, WithExtendedSuffixes())
+	assert.NoError(t, err)
+	assert.Equal(t, "parquet", f.Suffix)
+	assert.Equal(t, prefix+".parquet", f.GetDataFilename())
+	assert.Equal(t, FormatParquet, f.Format())
+
+	pc = MockPathChecker{map[string]bool{prefix + ".parquet.gz": true}}
+	f, err = CreateS3FileWithOptions(ctx, pc, bucket, "s", "t", "", expectedDate, WithExtendedSuffixes())
+	assert.NoError(t, err)
+	assert.Equal(t, "parquet.gz", f.Suffix)
+	assert.Equal(t, prefix+".parquet.gz", f.GetDataFilename())
+	assert.Equal(t, FormatParquet, f.Format())
+
+	f, err = CreateS3FileWithSuffixes(pc, bucket, "s", "t", "", expectedDate, ExtendedSuffixes())
+	assert.NoError(t, err)
+	assert.Equal(t, "parquet.gz", f.Suffix)
+}
+
+func TestFormat(t *testing.T) {
+	for suffix, format := range map[string]FileFormat{
+		"manifest":   FormatUnknown,
+		"json.gz":    FormatJSON,
+		"json":       FormatJSON,
+		"parquet.gz": FormatParquet,
+		"parquet":    FormatParquet,
+		".gz":        FormatCSV,
+		"":           FormatCSV,
+	} {
+		f := S3File{Suffix: suffix}
+		assert.Equal(t, format, f.Format(), "suffix: %q", suffix)
+	}
+	assert.Equal(t, "PARQUET", FormatParquet.String())
+	assert.Equal(t, "FileFormat(7)", FileFormat(7).String())
+}
+
+func TestCreateS3FileWithSuffixes(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	prefix := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z"
+	pc := MockPathChecker{map[string]bool{
+		prefix + ".json":    true,
+		prefix + ".parquet": true,
+		prefix:              true,
+	}}
+
+	// first match wins
+	f, err := CreateS3FileWithSuffixes(pc, bucket, "s", "t", "", expectedDate, []string{"parquet", "json"})
+	assert.NoError(t, err)
+	assert.Equal(t, "parquet", f.Suffix)
+
+	f, err = CreateS3FileWithSuffixes(pc, bucket, "s", "t", "", expectedDate, []string{"json", "parquet"})
+	assert.NoError(t, err)
+	assert.Equal(t, "json", f.Suffix)
+
+	// plain csv is still expressible
+	f, err = CreateS3FileWithSuffixes(pc, bucket, "s", "t", "", expectedDate, []string{"json.gz", ""})
+	assert.NoError(t, err)
+	assert.Equal(t, "", f.Suffix)
+
+	_, err = CreateS3FileWithSuffixes(pc, bucket, "s", "t", "", expectedDate, []string{"json.gz"})
+	assert.Error(t, err)
+
+	// the default list is unchanged by callers appending to it
+	suffixes := append(DefaultSuffixes(), "parquet")
+	assert.Equal(t, len(defaultSuffixes)+1, len(suffixes))
+	assert.NotEqual(t, "parquet", defaultSuffixes[len(defaultSuffixes)-1])
+}
+
+// appearingPathChecker only finds files after a number of checks
+type appearingPathChecker struct {
+	checks   int
+	appearAt int
+	path     string
+}
+
+func (ap *appearingPathChecker) FileExists(path string) bool {
+	ap.checks++
+	return ap.checks >= ap.appearAt && path == ap.path
+}
+
+func TestWaitForFile(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	jsonPath := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z.json"
+
+	// shows up on the third round of polling
+	pc := &appearingPathChecker{appearAt: 2*len(defaultSuffixes) + 1, path: jsonPath}
+	f, err := WaitForFile(context.Background(), pc, bucket, "s", "t", expectedDate, time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, jsonPath, f.GetDataFilename())
+
+	// never shows up
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	pc = &appearingPathChecker{path: "never"}
+	_, err = WaitForFile(ctx, pc, bucket, "s", "t", expectedDate, time.Millisecond)
+	assert.EqualError(t, err, "stopped waiting for s3 file at: bucket: b schema: s, table: t date: 2015-11-10T23:00:00Z: context deadline exceeded")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// a failed lookup won't fix itself, so it's returned without waiting
+	denied := awserr.NewRequestFailure(awserr.New("AccessDenied", "access denied", nil), 403, "id")
+	failing := newFailingPathChecker(denied)
+	_, err = WaitForFile(context.Background(), failing, bucket, "s", "t", expectedDate, time.Hour)
+	var reqErr awserr.RequestFailure
+	assert.True(t, errors.As(err, &reqErr))
+	assert.Len(t, failing.Checked(), 1)
+
+	// cancelling stops the wait between polls
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	_, err = WaitForFile(ctx, pc, bucket, "s", "t", expectedDate, time.Hour)
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < time.Minute)
+
+	// a zero interval, e.g. from an unset flag, is an error rather than a panic
+	empty := NewInMemoryPathChecker()
+	_, err = WaitForFile(context.Background(), empty, bucket, "s", "t", expectedDate, 0)
+	assert.EqualError(t, err, "interval must be positive, got 0s")
+	assert.Empty(t, empty.Checked())
+}
+
+func TestFindLatestFile(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	olderPath := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=07/s_t_2015-11-07T23:00:00Z.json"
+	oldestPath := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=01/s_t_2015-11-01T23:00:00Z.json"
+	pc := MockPathChecker{map[string]bool{olderPath: true, oldestPath: true}}
+
+	f, err := FindLatestFile(pc, bucket, "s", "t", expectedDate, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, olderPath, f.GetDataFilename())
+
+	// the window includes maxDaysBack
+	f, err = FindLatestFile(pc, bucket, "s", "t", expectedDate, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, olderPath, f.GetDataFilename())
+
+	_, err = FindLatestFile(pc, bucket, "s", "t", expectedDate, 2)
+	assert.EqualError(t, err, "no s3 file found for bucket: b schema: s, table: t between 2015-11-08T23:00:00Z and 2015-11-10T23:00:00Z")
+}
+
+// cancellingPathChecker records the paths checked and cancels the context after cancelAfter checks
+type cancellingPathChecker struct {
+	MockPathChecker
+	cancelAfter int
+	cancel      context.CancelFunc
+	checked     []string
+}
+
+func (cp *cancellingPathChecker) FileExists(path string) bool {
+	cp.checked = append(cp.checked, path)
+	if len(cp.checked) == cp.cancelAfter {
+		cp.cancel()
+	}
+	return cp.MockPathChecker.FileExists(path)
+}
+
+func TestFindLatestFileContext(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	// written every day up to the 3rd, then the table stopped
+	existing := map[string]bool{}
+	for day := 1; day <= 3; day++ {
+		date := time.Date(2015, 11, day, 23, 0, 0, 0, time.UTC)
+		existing[buildS3File(bucket, "s", "t", "", date, "json").GetDataFilename()] = true
+	}
+	latest := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=03/s_t_2015-11-03T23:00:00Z.json"
+
+	// found: checks the 10th and 3rd, then refines the days in between
+	pc := &cancellingPathChecker{MockPathChecker: MockPathChecker{existing}, cancel: func() {}}
+	f, err := FindLatestFileContext(context.Background(), pc, bucket, "s", "t", expectedDate, 30, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, latest, f.GetDataFilename())
+	daily, err := FindLatestFile(MockPathChecker{existing}, bucket, "s", "t", expectedDate, 30)
+	assert.NoError(t, err)
+	assert.Equal(t, latest, daily.GetDataFilename())
+
+	// a stride that goes past maxDaysBack still checks maxDaysBack: checks the 10th, 4th and 2nd,
+	// then finds the 3rd when refining
+	f, err = FindLatestFileContext(context.Background(), pc, bucket, "s", "t", expectedDate, 8, 6)
+	assert.NoError(t, err)
+	assert.Equal(t, latest, f.GetDataFilename())
+
+	// not found
+	_, err = FindLatestFileContext(context.Background(), MockPathChecker{existing}, bucket, "s", "t", expectedDate, 6, 3)
+	assert.True(t, errors.Is(err, ErrNoRecentFile))
+	var noRecent *NoRecentFileError
+	assert.True(t, errors.As(err, &noRecent))
+	assert.Equal(t, "t", noRecent.Table)
+	assert.True(t, time.Date(2015, 11, 4, 23, 0, 0, 0, time.UTC).Equal(noRecent.From))
+	assert.True(t, expectedDate.Equal(noRecent.To))
+
+	// cancelled mid-scan
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pc = &cancellingPathChecker{MockPathChecker: MockPathChecker{existing}, cancelAfter: 3, cancel: cancel}
+	_, err = FindLatestFileContext(ctx, pc, bucket, "s", "t", expectedDate, 365, 1)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.False(t, errors.Is(err, ErrNoRecentFile))
+	assert.True(t, len(pc.checked) < 2*len(defaultSuffixes))
+}
+
+func TestListDatesInRange(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	day := func(d int) string {
+		return fmt.Sprintf("s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=%02d/s_t_2015-11-%02dT23:00:00Z", d, d)
+	}
+	pc := NewInMemoryPathChecker(
+		day(6)+".json.gz",
+		day(6)+".json",
+		day(8)+".manifest",
+		day(10),
+		day(11)+".json.gz", // outside the window
+	)
+
+	dates, err := ListDatesInRange(pc, bucket, "s", "t", expectedDate.AddDate(0, 0, -4), expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{
+		expectedDate.AddDate(0, 0, -4),
+		expectedDate.AddDate(0, 0, -2),
+		expectedDate,
+	}, dates)
+	// it stops at the first suffix found for a day
+	assert.Equal(t, 1, pc.Checks(day(6)+".json.gz"))
+	assert.Equal(t, 0, pc.Checks(day(6)+".json"))
+	assert.Equal(t, 0, pc.Checks(day(11)+".json.gz"))
+
+	dates, err = ListDatesInRange(pc, bucket, "s", "t", expectedDate, expectedDate.AddDate(0, 0, -1))
+	assert.NoError(t, err)
+	assert.Empty(t, dates)
+
+	_, err = ListDatesInRange(pc, bucket, "s", "bad-table", expectedDate, expectedDate)
+	assert.Error(t, err)
+}
+
+func TestFindS3Files(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	prefix := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z"
+	pc := MockPathChecker{map[string]bool{
+		prefix + ".json.gz": true,
+		prefix + ".json":    true,
+	}}
+
+	files, err := FindS3Files(pc, bucket, "s", "t", expectedDate)
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+	assert.Equal(t, prefix+".json.gz", files[0].GetDataFilename())
+	assert.Equal(t, prefix+".json", files[1].GetDataFilename())
+
+	files, err = FindS3Files(pc, bucket, "s", "other", expectedDate)
+	assert.Error(t, err)
+	assert.Nil(t, files)
+}
+
+// contextPathChecker cancels its context after a set number of checks
+type contextPathChecker struct {
+	checked  []string
+	cancelAt int
+	cancel   context.CancelFunc
+	existing map[string]bool
+}
+
+func (cp *contextPathChecker) FileExists(path string) bool {
+	return cp.FileExistsContext(context.Background(), path)
+}
+
+func (cp *contextPathChecker) FileExistsContext(ctx context.Context, path string) bool {
+	cp.checked = append(cp.checked, path)
+	if len(cp.checked) == cp.cancelAt {
+		cp.cancel()
+	}
+	return ctx.Err() == nil && cp.existing[path]
+}
+
+func TestCandidateConfigPaths(t *testing.T) {
+	folder := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/"
+	assert.Equal(t, []string{
+		folder + "config_s_t_2015-11-10T23:00:00Z.yml",
+		folder + "config_s_t_2015-11-10T23:00:00Z.yaml",
+	}, CandidateConfigPaths(S3Bucket{Name: "b"}, "s", "t", expectedDate))
+}
+
+func TestFindConfigFile(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	folder := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/"
+	tableConf := "s3://b/s/t/config_s_t.yml"
+
+	// the date's config wins over the table's
+	pc := NewInMemoryPathChecker(folder+"config_s_t_2015-11-10T23:00:00Z.yaml", tableConf)
+	confFile, err := FindConfigFile(pc, bucket, "s", "t", expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, folder+"config_s_t_2015-11-10T23:00:00Z.yaml", confFile)
+
+	pc = NewInMemoryPathChecker(tableConf)
+	confFile, err = FindConfigFile(pc, bucket, "s", "t", expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, tableConf, confFile)
+
+	pc = NewInMemoryPathChecker("s3://b/s/t/config_s_t.yaml")
+	confFile, err = FindConfigFile(pc, bucket, "s", "t", expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, "s3://b/s/t/config_s_t.yaml", confFile)
+
+	_, err = FindConfigFile(NewInMemoryPathChecker(), bucket, "s", "t", expectedDate)
+	assert.True(t, errors.Is(err, ErrConfigNotFound))
+	assert.Contains(t, err.Error(), folder+"config_s_t_2015-11-10T23:00:00Z.yml, ")
+	assert.Contains(t, err.Error(), tableConf+", s3://b/s/t/config_s_t.yaml")
+
+	_, err = FindConfigFile(newFailingPathChecker(errors.New("access denied")), bucket, "s", "t", expectedDate)
+	assert.False(t, errors.Is(err, ErrConfigNotFound))
+	assert.Contains(t, err.Error(), "access denied")
+
+	// named like createS3File names them
+	pc = NewInMemoryPathChecker("s3://b/s/t/config-s-t.yml")
+	confFile, err = FindConfigFile(pc, bucket, "s", "t", expectedDate, WithSeparator("-"))
+	assert.NoError(t, err)
+	assert.Equal(t, "s3://b/s/t/config-s-t.yml", confFile)
+	_, err = FindConfigFile(NewInMemoryPathChecker(tableConf), bucket, "s", "t", expectedDate, WithSeparator("-"))
+	assert.True(t, errors.Is(err, ErrConfigNotFound))
+
+	pc = NewInMemoryPathChecker("s3://b/s/t/config_t.yaml")
+	confFile, err = FindConfigFile(pc, bucket, "s", "t", expectedDate, WithoutSchemaPrefix())
+	assert.NoError(t, err)
+	assert.Equal(t, "s3://b/s/t/config_t.yaml", confFile)
+
+	namer := func(schema, table string, date time.Time) string { return schema + "." + table + ".yml" }
+	pc = NewInMemoryPathChecker(folder+"s.t.yml", tableConf)
+	confFile, err = FindConfigFile(pc, bucket, "s", "t", expectedDate, WithConfigNamer(namer))
+	assert.NoError(t, err)
+	assert.Equal(t, folder+"s.t.yml", confFile)
+	_, err = FindConfigFile(NewInMemoryPathChecker(tableConf), bucket, "s", "t", expectedDate, WithConfigNamer(namer))
+	assert.True(t, errors.Is(err, ErrConfigNotFound))
+
+	_, err = FindConfigFile(pc, bucket, "s", "t", expectedDate, WithSeparator("/"))
+	assert.Error(t, err)
+}
+
+func TestCandidateDataPaths(t *testing.T) {
+	prefix := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z"
+	assert.Equal(t, []string{
+		prefix + ".manifest",
+		prefix + ".json.gz",
+		prefix + ".json",
+		prefix + ".gz",
+		prefix,
+	}, CandidateDataPaths(S3Bucket{Name: "b"}, "s", "t", expectedDate))
+
+	_, err := CreateS3FileWithSuffixes(MockPathChecker{}, S3Bucket{Name: "b"}, "s", "t", "", expectedDate, []string{"json"})
+	var notFound *FileNotFoundError
+	assert.True(t, errors.As(err, &notFound))
+	assert.Equal(t, []string{prefix + ".json"}, notFound.Candidates)
+	assert.Contains(t, err.Error(), prefix+".json")
+}
+
+func TestDataFilenameForSuffix(t *testing.T) {
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json.gz")
+	prefix := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z"
+	for _, test := range []struct {
+		suffix   string
+		expected string
+	}{
+		{"json.gz", prefix + ".json.gz"},
+		{".json.gz", prefix + ".json.gz"},
+		{"manifest", prefix + ".manifest"},
+		{"parquet", prefix + ".parquet"},
+		{".gz", prefix + ".gz"},
+		{"gz", prefix + ".gz"},
+		{"", prefix},
+		{".", prefix},
+	} {
+		assert.Equal(t, test.expected, f.DataFilenameForSuffix(test.suffix), "suffix %q", test.suffix)
+	}
+	// f is unchanged
+	assert.Equal(t, "json.gz", f.Suffix)
+	assert.Equal(t, f.GetDataFilename(), f.DataFilenameForSuffix(f.Suffix))
+
+	// parts keep their name
+	f.Part = "part-00000"
+	assert.Equal(t, "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/part-00000.parquet",
+		f.DataFilenameForSuffix(".parquet"))
+}
+
+func TestCandidateFilenames(t *testing.T) {
+	f := S3File{
+		Bucket:    S3Bucket{Name: "b"},
+		Schema:    "s",
+		Table:     "t",
+		DataDate:  expectedDate,
+		Suffix:    "json.gz",
+		Subfolder: "sub",
+	}
+	prefix := "s3://b/sub/s_t_2015-11-10T23:00:00Z"
+	assert.Equal(t, []string{
+		prefix + ".manifest",
+		prefix + ".json.gz",
+		prefix + ".json",
+		prefix + ".gz",
+		prefix,
+	}, f.CandidateFilenames())
+	assert.Equal(t, "json.gz", f.Suffix)
+
+	// files looked for WithExtendedSuffixes include the parquet ones
+	WithExtendedSuffixes()(&f)
+	assert.Equal(t, []string{
+		prefix + ".manifest",
+		prefix + ".json.gz",
+		prefix + ".json.bz2",
+		prefix + ".json.zst",
+		prefix + ".json",
+		prefix + ".parquet.gz",
+		prefix + ".parquet",
+		prefix + ".gz",
+		prefix + ".bz2",
+		prefix + ".zst",
+		prefix,
+	}, f.CandidateFilenames())
+}
+
+func TestCreateS3FileErrors(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	denied := awserr.NewRequestFailure(awserr.New("AccessDenied", "access denied", nil), 403, "id")
+
+	// a genuine miss
+	_, err := CreateS3File(NewInMemoryPathChecker(), bucket, "s", "t", "", expectedDate)
+	assert.True(t, errors.Is(err, ErrFileNotFound))
+	var notFound *FileNotFoundError
+	assert.True(t, errors.As(err, &notFound))
+	assert.Equal(t, "t", notFound.Table)
+	assert.True(t, expectedDate.Equal(notFound.Date))
+
+	// a failed lookup isn't a miss, and wraps the error
+	_, err = CreateS3File(newFailingPathChecker(denied), bucket, "s", "t", "", expectedDate)
+	assert.False(t, errors.Is(err, ErrFileNotFound))
+	var reqErr awserr.RequestFailure
+	assert.True(t, errors.As(err, &reqErr))
+	assert.Equal(t, 403, reqErr.StatusCode())
+
+	_, err = FindS3Files(newFailingPathChecker(denied), bucket, "s", "t", expectedDate)
+	assert.True(t, errors.As(err, &reqErr))
+}
+
+func TestLookupError(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	prefix := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z"
+	denied := awserr.NewRequestFailure(awserr.New("AccessDenied", "access denied", nil), 403, "id")
+
+	// every suffix is tried and missed
+	_, err := CreateS3FileWithSuffixes(NewInMemoryPathChecker(), bucket, "s", "t", "", expectedDate, []string{"manifest", "json.gz", ""})
+	var lookupErr *LookupError
+	assert.True(t, errors.As(err, &lookupErr))
+	assert.Equal(t, []LookupAttempt{
+		{Suffix: "manifest", Path: prefix + ".manifest"},
+		{Suffix: "json.gz", Path: prefix + ".json.gz"},
+		{Suffix: "", Path: prefix},
+	}, lookupErr.Attempts)
+	assert.False(t, lookupErr.Errored())
+	assert.True(t, errors.Is(err, ErrFileNotFound))
+	assert.Equal(t, "s3 file not found at: bucket: b schema: s, table: t date: 2015-11-10T23:00:00Z, tried: "+
+		prefix+".manifest, "+prefix+".json.gz, "+prefix, err.Error())
+
+	_, err = FindS3Files(NewInMemoryPathChecker(), bucket, "s", "t", expectedDate)
+	assert.True(t, errors.As(err, &lookupErr))
+	assert.Len(t, lookupErr.Attempts, len(defaultSuffixes))
+	assert.False(t, lookupErr.Errored())
+
+	// a failed lookup stops the search and is the last attempt
+	_, err = CreateS3File(newFailingPathChecker(nil, denied), bucket, "s", "t", "", expectedDate)
+	assert.True(t, errors.As(err, &lookupErr))
+	assert.Equal(t, []LookupAttempt{
+		{Suffix: "manifest", Path: prefix + ".manifest"},
+		{Suffix: "json.gz", Path: prefix + ".json.gz", Err: denied},
+	}, lookupErr.Attempts)
+	assert.True(t, lookupErr.Errored())
+	assert.False(t, errors.Is(err, ErrFileNotFound))
+
+	_, err = FindS3Files(newFailingPathChecker(denied), bucket, "s", "t", expectedDate)
+	assert.True(t, errors.As(err, &lookupErr))
+	assert.True(t, lookupErr.Errored())
+	assert.Len(t, lookupErr.Attempts, 1)
+}
+
+func TestCreateS3FileContext(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	jsonPath := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z.json"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pc := &contextPathChecker{cancelAt: 2, cancel: cancel, existing: map[string]bool{jsonPath: true}}
+	_, err := CreateS3FileContext(ctx, pc, bucket, "s", "t", "", expectedDate)
+	assert.Equal(t, context.Canceled, err)
+	// stops after the check that cancelled the context
+	assert.Len(t, pc.checked, 2)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	pc = &contextPathChecker{cancelAt: 0, cancel: cancel, existing: map[string]bool{jsonPath: true}}
+	f, err := CreateS3FileContext(ctx, pc, bucket, "s", "t", "", expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, jsonPath, f.GetDataFilename())
+	cancel()
+
+	// a context that's already done doesn't check anything
+	_, err = CreateS3FileContext(ctx, pc, bucket, "s", "t", "", expectedDate)
+	assert.Equal(t, context.Canceled, err)
+	// three data files and two config files from the lookup above
+	assert.Len(t, pc.checked, 5)
+	assert.False(t, S3PathChecker{}.FileExistsContext(ctx, jsonPath))
+}
+
+func TestGetDataFilename(t *testing.T) {
+	prefix := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z"
+	for _, test := range []struct {
+		suffix   string
+		expected string
+	}{
+		{"manifest", prefix + ".manifest"},
+		{"json.gz", prefix + ".json.gz"},
+		{"json", prefix + ".json"},
+		{"parquet.gz", prefix + ".parquet.gz"},
+		{"parquet", prefix + ".parquet"},
+		{".gz", prefix + ".gz"},
+		{"gz", prefix + ".gz"},
+		{".json.gz", prefix + ".json.gz"},
+		{"", prefix},
+	} {
+		f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, test.suffix)
+		assert.Equal(t, test.expected, f.GetDataFilename(), "suffix: %q", test.suffix)
+	}
+}
+
+func TestGetConfigFilename(t *testing.T) {
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json")
+	expected := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/config_s_t_2015-11-10T23:00:00Z.yml"
+	assert.Equal(t, expected, f.GetConfigFilename())
+	assert.Equal(t, expected, f.ConfFile)
+
+	// still available when the config file was supplied
+	f = buildS3File(S3Bucket{Name: "b"}, "s", "t", "foo", expectedDate, "json")
+	assert.Equal(t, "foo", f.ConfFile)
+	assert.Equal(t, expected, f.GetConfigFilename())
+}
+
+func TestCreateS3FileYAMLConfig(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	folder := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/"
+	dataPath := folder + "s_t_2015-11-10T23:00:00Z.json"
+	ymlPath := folder + "config_s_t_2015-11-10T23:00:00Z.yml"
+	yamlPath := folder + "config_s_t_2015-11-10T23:00:00Z.yaml"
+
+	// .yaml is found when there's no .yml
+	f, err := CreateS3File(MockPathChecker{map[string]bool{dataPath: true, yamlPath: true}}, bucket, "s", "t", "", expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, yamlPath, f.ConfFile)
+
+	// .yml wins if both exist
+	f, err = CreateS3File(MockPathChecker{map[string]bool{dataPath: true, ymlPath: true, yamlPath: true}}, bucket, "s", "t", "", expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, ymlPath, f.ConfFile)
+
+	// supplied config files are used whatever their extension
+	f, err = CreateS3File(MockPathChecker{map[string]bool{dataPath: true, ymlPath: true}}, bucket, "s", "t", "s3://b/conf.yaml", expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, "s3://b/conf.yaml", f.ConfFile)
+
+	files, err := FindS3Files(MockPathChecker{map[string]bool{dataPath: true, yamlPath: true}}, bucket, "s", "t", expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, yamlPath, files[0].ConfFile)
+
+	assert.True(t, yamlRegex.MatchString(ymlPath))
+	assert.True(t, yamlRegex.MatchString(yamlPath))
+	assert.False(t, yamlRegex.MatchString(dataPath))
+}
+
+func TestDateLayout(t *testing.T) {
+	folder := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/"
+	for _, test := range []struct {
+		layout   string
+		expected string
+	}{
+		{"", "s_t_2015-11-10T23:00:00Z.json"},
+		{time.RFC3339, "s_t_2015-11-10T23:00:00Z.json"},
+		{"20060102", "s_t_20151110.json"},
+		{DateLayoutEpochSeconds, "s_t_1447196400.json"},
+		{DateLayoutEpochMillis, "s_t_1447196400000.json"},
+	} {
+		f := buildS3FileWithLayout(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json", test.layout)
+		assert.Equal(t, folder+test.expected, f.GetDataFilename(), "layout: %q", test.layout)
+		assert.Equal(t, folder+"config_"+strings.TrimSuffix(test.expected, ".json")+".yml", f.ConfFile, "layout: %q", test.layout)
+	}
+
+	pc := MockPathChecker{map[string]bool{folder + "s_t_20151110.json.gz": true}}
+	f, err := CreateS3FileWithDateLayout(pc, S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "20060102")
+	assert.NoError(t, err)
+	assert.Equal(t, "json.gz", f.Suffix)
+	assert.Equal(t, "20060102", f.DateLayout)
+
+	_, err = CreateS3FileWithDateLayout(pc, S3Bucket{Name: "b"}, "s", "t", "", expectedDate, DateLayoutEpochSeconds)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), folder+"s_t_1447196400.json.gz")
+}
+
+func TestParseDataFilenameEpoch(t *testing.T) {
+	folder := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/"
+	// 23:00:00.250 UTC on the 10th, which is still the 10th's partition
+	date := expectedDate.Add(250 * time.Millisecond)
+	for _, test := range []struct {
+		layout   string
+		filename string
+		date     time.Time
+	}{
+		{DateLayoutEpochSeconds, "s_t_1447196400.json.gz", expectedDate},
+		{DateLayoutEpochMillis, "s_t_1447196400250.json.gz", date},
+	} {
+		f, err := ParseDataFilenameWithOptions(folder+test.filename, WithDateLayout(test.layout))
+		assert.NoError(t, err, test.layout)
+		assert.True(t, test.date.Equal(f.DataDate), test.layout)
+		assert.Equal(t, time.UTC, f.DataDate.Location())
+		assert.Equal(t, "json.gz", f.Suffix)
+		assert.Equal(t, test.layout, f.DateLayout)
+		// and back again
+		assert.Equal(t, folder+test.filename, f.GetDataFilename())
+		assert.Equal(t, folder+"config_"+strings.TrimSuffix(test.filename, ".json.gz")+".yml", f.ConfFile)
+	}
+
+	// the partition folders have to match the epoch's UTC date
+	_, err := ParseDataFilenameWithOptions(folder+"s_t_1447200000.json", WithDateLayout(DateLayoutEpochSeconds))
+	assert.Error(t, err)
+	_, err = ParseDataFilenameWithOptions(folder+"s_t_2015-11-10T23:00:00Z.json", WithDateLayout(DateLayoutEpochSeconds))
+	assert.Error(t, err)
+	_, err = ParseDataFilename(folder + "s_t_1447196400.json")
+	assert.Error(t, err)
+
+	// other layouts parse too
+	f, err := ParseDataFilenameWithOptions(folder+"s_t_20151110.json", WithDateLayout("20060102"))
+	assert.NoError(t, err)
+	assert.Equal(t, folder+"s_t_20151110.json", f.GetDataFilename())
+}
+
+func TestCreateS3FileUTC(t *testing.T) {
+	// 11:30pm on the 10th in PST is already the 11th in UTC
+	pst := time.FixedZone("PST", -8*60*60)
+	date := time.Date(2015, time.November, 10, 23, 30, 0, 0, pst)
+	jsonPath := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=11/s_t_2015-11-11T07:30:00Z.json"
+
+	f, err := CreateS3File(MockPathChecker{map[string]bool{jsonPath: true}}, S3Bucket{Name: "b"}, "s", "t", "", date)
+	assert.NoError(t, err)
+	assert.Equal(t, "s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=11", f.Subfolder)
+	assert.Equal(t, time.UTC, f.DataDate.Location())
+	assert.True(t, date.Equal(f.DataDate))
+
+	// GetDataFilename formats in UTC even if DataDate was set in another location
+	f.DataDate = date
+	assert.Equal(t, jsonPath, f.GetDataFilename())
+}
+
+func TestCreateS3FileBzip2Zstd(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	folder := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/"
+	for suffix, format := range map[string]FileFormat{
+		"json.bz2": FormatJSON,
+		"json.zst": FormatJSON,
+		".bz2":     FormatCSV,
+		".zst":     FormatCSV,
+	} {
+		dataPath := folder + "s_t_2015-11-10T23:00:00Z." + normalizeSuffix(suffix)
+		pc := MockPathChecker{map[string]bool{dataPath: true}}
+		// not looked for by default
+		_, err := CreateS3File(pc, bucket, "s", "t", "", expectedDate)
+		assert.True(t, errors.Is(err, ErrFileNotFound))
+
+		f, err := CreateS3FileWithOptions(context.Background(), pc, bucket, "s", "t", "", expectedDate, WithExtendedSuffixes())
+		assert.NoError(t, err)
+		assert.Equal(t, suffix, f.Suffix)
+		assert.Equal(t, dataPath, f.GetDataFilename())
+		assert.Equal(t, format, f.Format())
+
+		// "bz2" and ".bz2" are the same file
+		f.Suffix = normalizeSuffix(suffix)
+		assert.Equal(t, dataPath, f.GetDataFilename())
+	}
+}
+
+func TestFilenamesGolden(t *testing.T) {
+	// these must stay byte-for-byte the same, they're what's already in our buckets
+	folder := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/"
+	for _, tc := range []struct {
+		suffix, data string
+	}{
+		{"manifest", folder + "s_t_2015-11-10T23:00:00Z.manifest"},
+		{"json.gz", folder + "s_t_2015-11-10T23:00:00Z.json.gz"},
+		{"json", folder + "s_t_2015-11-10T23:00:00Z.json"},
+		{"parquet.gz", folder + "s_t_2015-11-10T23:00:00Z.parquet.gz"},
+		{"parquet", folder + "s_t_2015-11-10T23:00:00Z.parquet"},
+		{".gz", folder + "s_t_2015-11-10T23:00:00Z.gz"},
+		{"json.bz2", folder + "s_t_2015-11-10T23:00:00Z.json.bz2"},
+		{"json.zst", folder + "s_t_2015-11-10T23:00:00Z.json.zst"},
+		{".bz2", folder + "s_t_2015-11-10T23:00:00Z.bz2"},
+		{".zst", folder + "s_t_2015-11-10T23:00:00Z.zst"},
+		{"", folder + "s_t_2015-11-10T23:00:00Z"},
+	} {
+		f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, tc.suffix)
+		assert.Equal(t, tc.data, f.GetDataFilename())
+		assert.Equal(t, folder+"config_s_t_2015-11-10T23:00:00Z.yml", f.ConfFile)
+		assert.Equal(t, folder, f.folder())
+	}
+
+	// no double slashes with an empty or slash-terminated subfolder
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json")
+	f.Subfolder = ""
+	assert.Equal(t, "s3://b/s_t_2015-11-10T23:00:00Z.json", f.GetDataFilename())
+	assert.Equal(t, "s3://b/config_s_t_2015-11-10T23:00:00Z.yml", f.GetConfigFilename())
+	assert.Equal(t, "s3://b/", f.folder())
+	f.Subfolder = "/s/t/"
+	assert.Equal(t, "s3://b/s/t/s_t_2015-11-10T23:00:00Z.json", f.GetDataFilename())
+	assert.Equal(t, "s3://b/s/t/", f.folder())
+}
+
+func TestGetDataPrefix(t *testing.T) {
+	prefix := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z"
+	for _, suffix := range defaultSuffixes {
+		f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, suffix)
+		assert.Equal(t, prefix, f.GetDataPrefix())
+		assert.Equal(t, f.GetDataFilename(), f.GetDataPrefix()+formatSuffix(suffix))
+	}
+
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "")
+	assert.Equal(t, prefix+"*.json.gz", f.GetDataGlob("json.gz"))
+	assert.Equal(t, prefix+"*.gz", f.GetDataGlob(".gz"))
+	assert.Equal(t, prefix+"*", f.GetDataGlob(""))
+}
+
+func TestBucketPrefix(t *testing.T) {
+	folder := "s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/"
+	for _, test := range []struct {
+		prefix   string
+		expected string
+	}{
+		{"", "s3://b/" + folder},
+		{"datalake/prod", "s3://b/datalake/prod/" + folder},
+		{"/datalake/prod/", "s3://b/datalake/prod/" + folder},
+		{"/", "s3://b/" + folder},
+	} {
+		bucket := S3Bucket{Name: "b", Prefix: test.prefix}
+		f := buildS3File(bucket, "s", "t", "", expectedDate, "json.gz")
+		assert.Equal(t, test.expected+"s_t_2015-11-10T23:00:00Z.json.gz", f.GetDataFilename(), "prefix %q", test.prefix)
+		assert.Equal(t, test.expected+"config_s_t_2015-11-10T23:00:00Z.yml", f.ConfFile, "prefix %q", test.prefix)
+		assert.Equal(t, test.expected, f.folder(), "prefix %q", test.prefix)
+
+		found, err := CreateS3File(NewInMemoryPathChecker(f.GetDataFilename()), bucket, "s", "t", "", expectedDate)
+		assert.NoError(t, err)
+		assert.Equal(t, f, found)
+	}
+
+	// folder is just the bucket prefix when there's no subfolder
+	f := buildS3File(S3Bucket{Name: "b", Prefix: "datalake"}, "s", "t", "", expectedDate, "json")
+	f.Subfolder = ""
+	assert.Equal(t, "s3://b/datalake/", f.folder())
+	f.Bucket.Prefix = ""
+	assert.Equal(t, "s3://b/", f.folder())
+}
+
+func TestGetDataFilenameEmptySuffix(t *testing.T) {
+	// UNLOADed csv files have no suffix, so there shouldn't be a trailing period either
+	f := S3File{
+		Bucket:    S3Bucket{Name: "b"},
+		Schema:    "s",
+		Table:     "t",
+		Suffix:    "",
+		DataDate:  expectedDate,
+		Subfolder: "s/t",
+	}
+	assert.Equal(t, "s3://b/s/t/s_t_2015-11-10T23:00:00Z", f.GetDataFilename())
+	assert.False(t, strings.HasSuffix(f.GetDataFilename(), "."))
+
+	parsed, err := ParseDataFilename("s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z")
+	assert.NoError(t, err)
+	assert.Equal(t, "", parsed.Suffix)
+	assert.True(t, expectedDate.Equal(parsed.DataDate))
+}
+
+func TestParseDataFilename(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	for _, suffix := range defaultSuffixes {
+		f := buildS3File(bucket, "s", "t", "", expectedDate, suffix)
+		parsed, err := ParseDataFilename(f.GetDataFilename())
+		assert.NoError(t, err)
+		assert.Equal(t, f, parsed)
+	}
+
+	parsed, err := ParseDataFilename("s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z.json.gz")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", parsed.Bucket.Name)
+	assert.Equal(t, "s", parsed.Schema)
+	assert.Equal(t, "t", parsed.Table)
+	assert.Equal(t, "json.gz", parsed.Suffix)
+	assert.True(t, expectedDate.Equal(parsed.DataDate))
+
+	// schema and table names with underscores shouldn't corrupt the date
+	for _, tc := range []struct{ schema, table string }{
+		{"s", "order_line_items"},
+		{"user_events", "t"},
+		{"my_schema", "order_line_items"},
+	} {
+		f := buildS3File(bucket, tc.schema, tc.table, "", expectedDate, "json.gz")
+		parsed, err := ParseDataFilename(f.GetDataFilename())
+		assert.NoError(t, err)
+		assert.Equal(t, f, parsed)
+		assert.True(t, expectedDate.Equal(parsed.DataDate))
+	}
+
+	for _, bad := range []string{
+		"b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z.json",
+		"s3://b/s/t/s_t_2015-11-10T23:00:00Z.json",
+		"s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/x_y_2015-11-10T23:00:00Z.json",
+		"s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_notadate.json",
+		"s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=09/s_t_2015-11-10T23:00:00Z.json",
+		"s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_extra_2015-11-10T23:00:00Z.json",
+	} {
+		_, err := ParseDataFilename(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestS3FileEqual(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	f := buildS3File(bucket, "s", "t", "", expectedDate, "json.gz")
+
+	// same instant in another location, and different derived fields
+	other := buildS3File(S3Bucket{Name: "b", Region: "us-west-2"}, "s", "t", "s3://b/conf.yml", expectedDate, "json.gz")
+	other.DataDate = expectedDate.In(time.FixedZone("PST", -8*60*60))
+	other.Object = S3ObjectInfo{Size: 10}
+	assert.True(t, f.Equal(other))
+	assert.True(t, other.Equal(f))
+
+	assert.True(t, buildS3File(bucket, "s", "t", "", expectedDate, ".gz").Equal(buildS3File(bucket, "s", "t", "", expectedDate, "gz")))
+
+	for _, different := range []*S3File{
+		buildS3File(S3Bucket{Name: "other"}, "s", "t", "", expectedDate, "json.gz"),
+		buildS3File(bucket, "x", "t", "", expectedDate, "json.gz"),
+		buildS3File(bucket, "s", "x", "", expectedDate, "json.gz"),
+		buildS3File(bucket, "s", "t", "", expectedDate, "json"),
+		buildS3File(bucket, "s", "t", "", expectedDate.Add(time.Second), "json.gz"),
+		nil,
+	} {
+		assert.False(t, f.Equal(different))
+	}
+	var nilFile *S3File
+	assert.True(t, nilFile.Equal(nil))
+}
+
+func TestS3FileString(t *testing.T) {
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json.gz")
+	assert.Equal(t, `s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z.json.gz (suffix "json.gz")`, f.String())
+
+	f.Suffix = ""
+	assert.Equal(t, `s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z (suffix "")`, f.String())
+}
+
+func TestIsNotFound(t *testing.T) {
+	assert.True(t, isNotFound(awserr.New("NoSuchKey", "no such key", nil)))
+	assert.True(t, isNotFound(awserr.NewRequestFailure(awserr.New("NotFound", "not found", nil), 404, "id")))
+	assert.False(t, isNotFound(awserr.NewRequestFailure(awserr.New("AccessDenied", "denied", nil), 403, "id")))
+	_, err := os.Open(filepath.Join(os.TempDir(), "s3filepath-does-not-exist"))
+	assert.True(t, isNotFound(err))
+	assert.False(t, isNotFound(errors.New("connection reset")))
+
+	exists, err := S3PathChecker{}.FileExistsErr(filepath.Join(os.TempDir(), "s3filepath-does-not-exist"))
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestS3PathCheckerFileExistsErr(t *testing.T) {
+	file, err := ioutil.TempFile("", "s3filepath")
+	assert.NoError(t, err)
+	file.Close()
+	defer os.Remove(file.Name())
+
+	exists, err := S3PathChecker{}.FileExistsErr(file.Name())
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	// a missing file is just a miss
+	exists, err = S3PathChecker{}.FileExistsErr(file.Name() + "-missing")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	// anything else, like a file used as a directory, is an error that FileExists hides
+	exists, err = S3PathChecker{}.FileExistsErr(filepath.Join(file.Name(), "child"))
+	assert.Error(t, err)
+	assert.False(t, exists)
+	assert.False(t, S3PathChecker{}.FileExists(filepath.Join(file.Name(), "child")))
+
+	// it isn't a Statter, so CreateS3File's lookups go through FileExistsErr and see the error too
+	var pc PathChecker = S3PathChecker{}
+	_, isStatter := pc.(Statter)
+	assert.False(t, isStatter)
+	exists, info, err := lookup(context.Background(), pc, filepath.Join(file.Name(), "child"))
+	assert.Error(t, err)
+	assert.False(t, exists)
+	assert.Equal(t, S3ObjectInfo{}, info)
+	_, isStatter = PathChecker(S3StatPathChecker{}).(Statter)
+	assert.True(t, isStatter)
+}
+
+// countingLogger counts events, safely from several goroutines
+type countingLogger struct {
+	mu     sync.Mutex
+	events int
+}
+
+func (l *countingLogger) Log(string, map[string]interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events++
+}
+
+// TestS3PathCheckerConcurrent is most useful with go test -race
+func TestS3PathCheckerConcurrent(t *testing.T) {
+	file, err := ioutil.TempFile("", "s3filepath")
+	assert.NoError(t, err)
+	file.Close()
+	defer os.Remove(file.Name())
+	defer SetLogger(nil)
+
+	pc := S3PathChecker{}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			assert.True(t, pc.FileExists(file.Name()))
+		}()
+		go func() {
+			defer wg.Done()
+			// an error, which is logged
+			assert.False(t, pc.FileExists(filepath.Join(file.Name(), "child")))
+		}()
+		go func() {
+			defer wg.Done()
+			// swapping the logger mid-lookup is fine too
+			SetLogger(&countingLogger{})
+		}()
+	}
+	wg.Wait()
+}
+
+func TestResolveExpectedFile(t *testing.T) {
+	bucket := S3Bucket{Name: "b", Region: "r", RedshiftRoleARN: "arn"}
+	expFolder := "s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10"
+	expConf := "s3://b/" + expFolder + "/config_s_t_2015-11-10T23:00:00Z.yml"
+	expFile := getTestFileWithResults("b", "s", "t", "r", "arn", expFolder, expConf, "json.gz", expectedDate)
+
+	f := ResolveExpectedFile(bucket, "s", "t", "", expectedDate, "json.gz")
+	assert.Equal(t, expFile, *f)
+
+	// it matches what a real lookup finds
+	found, err := CreateS3File(MockPathChecker{map[string]bool{f.GetDataFilename(): true}}, bucket, "s", "t", "", expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, f, found)
+
+	f = ResolveExpectedFile(bucket, "s", "t", "s3://b/conf.yml", expectedDate, "")
+	assert.Equal(t, "s3://b/conf.yml", f.ConfFile)
+	assert.Equal(t, "s3://b/"+expFolder+"/s_t_2015-11-10T23:00:00Z", f.GetDataFilename())
+}
@@ -0,0 +1,44 @@
+package s3filepath
+
+import (
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// SDKPathChecker checks for files with HeadObject on an s3 client the caller has already
+// configured, so its credentials, endpoint (e.g. for MinIO) and retries are reused.
+// Unlike S3PathChecker it doesn't go through pathio or look up each bucket's region,
+// so the client has to be able to reach every bucket it's asked about.
+//
+// Note this uses aws-sdk-go (v1), which is what the rest of this repo is pinned to,
+// rather than aws-sdk-go-v2.
+type SDKPathChecker struct {
+	client headObjectAPI
+}
+
+// NewSDKPathChecker returns an SDKPathChecker using the given client
+func NewSDKPathChecker(client *s3.S3) SDKPathChecker {
+	return SDKPathChecker{client: client}
+}
+
+// FileExists returns whether the file exists. Errors other than the file not existing
+// are treated as the file not existing, use FileExistsErr to see them.
+func (c SDKPathChecker) FileExists(path string) bool {
+	return existsOrFalse(c.FileExistsErr(path))
+}
+
+// FileExistsErr returns whether the file exists. A 404 or NotFound is (false, nil),
+// any other error from S3 is returned.
+func (c SDKPathChecker) FileExistsErr(path string) (bool, error) {
+	if _, err := c.Stat(path); err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Stat looks up the object's metadata with a HEAD request
+func (c SDKPathChecker) Stat(path string) (S3ObjectInfo, error) {
+	return headObject(c.client, path)
+}
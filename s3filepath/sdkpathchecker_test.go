@@ -0,0 +1,44 @@
+package s3filepath
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSDKPathChecker(t *testing.T) {
+	m := &mockHeadObject{output: &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(1024),
+		LastModified:  aws.Time(expectedDate),
+		ETag:          aws.String(`"abc"`),
+	}}
+	pc := SDKPathChecker{client: m}
+
+	exists, err := pc.FileExistsErr("s3://b/s/t/file.json")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "b", aws.StringValue(m.input.Bucket))
+	assert.Equal(t, "s/t/file.json", aws.StringValue(m.input.Key))
+
+	// CreateS3File picks up the metadata since it's a Statter
+	f, err := CreateS3File(pc, S3Bucket{Name: "b"}, "s", "t", "", expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, S3ObjectInfo{Size: 1024, LastModified: expectedDate, ETag: "abc"}, f.Object)
+
+	m.err = awserr.NewRequestFailure(awserr.New("NotFound", "not found", nil), 404, "id")
+	exists, err = pc.FileExistsErr("s3://b/s/t/file.json")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	m.err = awserr.NewRequestFailure(awserr.New("AccessDenied", "denied", nil), 403, "id")
+	exists, err = pc.FileExistsErr("s3://b/s/t/file.json")
+	assert.Error(t, err)
+	assert.False(t, exists)
+	assert.False(t, pc.FileExists("s3://b/s/t/file.json"))
+
+	_, err = pc.FileExistsErr("b/s/t/file.json")
+	assert.Error(t, err)
+}
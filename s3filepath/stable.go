@@ -0,0 +1,58 @@
+package s3filepath
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// WaitForStableFile is WaitForFile for files that may still be uploading: once the data file
+// shows up, it keeps polling its size every interval until stablePolls lookups in a row (at
+// least two) report the same size, which approximates the upload being done. The returned
+// S3File's Object is the metadata from the last lookup. The interval must be positive.
+//
+// pc has to also be a Statter to look up the size, e.g. S3StatPathChecker.
+func WaitForStableFile(ctx context.Context, pc PathChecker, bucket S3Bucket, schema, table string, date time.Time,
+	interval time.Duration, stablePolls int) (*S3File, error) {
+	statter, ok := pc.(Statter)
+	if !ok {
+		return nil, fmt.Errorf("can't tell when the upload is done without a Statter, got %T", pc)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("interval must be positive, got %s", interval)
+	}
+	if stablePolls < 2 {
+		stablePolls = 2
+	}
+	f, err := WaitForFile(ctx, pc, bucket, schema, table, date, interval)
+	if err != nil {
+		return nil, err
+	}
+	dataPath := f.GetDataFilename()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var last S3ObjectInfo
+	for unchanged := 0; ; {
+		info, err := statter.Stat(dataPath)
+		if err != nil {
+			return nil, fmt.Errorf("error looking up the size of %s: %w", dataPath, err)
+		}
+		if unchanged > 0 && info.Size == last.Size {
+			unchanged++
+		} else {
+			unchanged = 1
+		}
+		last = info
+		if unchanged >= stablePolls {
+			f.Object = info
+			return f, nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("stopped waiting for %s to finish uploading at %d bytes: %w",
+				dataPath, last.Size, ctx.Err())
+		}
+	}
+}
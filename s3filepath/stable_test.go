@@ -0,0 +1,80 @@
+package s3filepath
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/assert"
+)
+
+// growingStatter reports the object growing by the given sizes, then staying at the last one
+type growingStatter struct {
+	path  string
+	sizes []int64
+	stats int
+}
+
+func (gs *growingStatter) FileExists(path string) bool {
+	return path == gs.path
+}
+
+func (gs *growingStatter) Stat(path string) (S3ObjectInfo, error) {
+	if path != gs.path {
+		return S3ObjectInfo{}, awserr.NewRequestFailure(awserr.New("NotFound", "not found", nil), 404, "id")
+	}
+	i := gs.stats
+	if i >= len(gs.sizes) {
+		i = len(gs.sizes) - 1
+	}
+	gs.stats++
+	return S3ObjectInfo{Size: gs.sizes[i]}, nil
+}
+
+func TestWaitForStableFile(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	jsonPath := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z.json"
+
+	// CreateS3File's own Stat sees 10 bytes, then WaitForStableFile's see 20, 30, 30
+	pc := &growingStatter{path: jsonPath, sizes: []int64{10, 20, 30}}
+	f, err := WaitForStableFile(context.Background(), pc, bucket, "s", "t", expectedDate, time.Millisecond, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, jsonPath, f.GetDataFilename())
+	assert.Equal(t, int64(30), f.Object.Size)
+	assert.Equal(t, 4, pc.stats)
+
+	// a higher stability count polls for longer, a lower one is raised to two
+	pc = &growingStatter{path: jsonPath, sizes: []int64{10, 20, 30}}
+	_, err = WaitForStableFile(context.Background(), pc, bucket, "s", "t", expectedDate, time.Millisecond, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, pc.stats)
+	pc = &growingStatter{path: jsonPath, sizes: []int64{10}}
+	_, err = WaitForStableFile(context.Background(), pc, bucket, "s", "t", expectedDate, time.Millisecond, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, pc.stats)
+
+	// never finishes uploading
+	sizes := make([]int64, 1000)
+	for i := range sizes {
+		sizes[i] = int64(i)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = WaitForStableFile(ctx, &growingStatter{path: jsonPath, sizes: sizes}, bucket, "s", "t", expectedDate,
+		time.Millisecond, 2)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "stopped waiting for "+jsonPath+" to finish uploading")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	_, err = WaitForStableFile(context.Background(), MockPathChecker{map[string]bool{jsonPath: true}}, bucket,
+		"s", "t", expectedDate, time.Millisecond, 2)
+	assert.EqualError(t, err, "can't tell when the upload is done without a Statter, got s3filepath.MockPathChecker")
+
+	// a zero interval is an error before anything is looked up
+	pc = &growingStatter{path: jsonPath, sizes: []int64{10}}
+	_, err = WaitForStableFile(context.Background(), pc, bucket, "s", "t", expectedDate, 0, 2)
+	assert.EqualError(t, err, "interval must be positive, got 0s")
+	assert.Equal(t, 0, pc.stats)
+}
@@ -0,0 +1,82 @@
+package s3filepath
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// S3ObjectInfo is what S3 knows about a data file
+type S3ObjectInfo struct {
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	ETag         string    `json:"etag"`
+}
+
+// LoadToken returns a stable idempotency key for the S3File's data: its bucket and key plus its
+// ETag, e.g. s3://bucket/key@etag, so loads can be deduped by content. The ETag is only known
+// when the file was found by a Statter (see Object); without it the token is just the path.
+//
+// ETags of objects uploaded in several parts have a -N suffix and aren't an MD5 of the content,
+// but they still change whenever the object is rewritten, which is unique enough for deduping.
+func (f *S3File) LoadToken() string {
+	if f.Object.ETag == "" {
+		return f.GetDataFilename()
+	}
+	return f.GetDataFilename() + "@" + f.Object.ETag
+}
+
+// Statter looks up an object's metadata. A missing object is an error, in the same
+// way it's returned from S3 (i.e. a 404). When a PathChecker is also a Statter,
+// CreateS3File uses Stat to look for files so the metadata comes for free.
+type Statter interface {
+	Stat(path string) (S3ObjectInfo, error)
+}
+
+// headObjectAPI is the part of the s3 client needed for Stat
+type headObjectAPI interface {
+	HeadObject(*s3.HeadObjectInput) (*s3.HeadObjectOutput, error)
+}
+
+// S3StatPathChecker is S3PathChecker, but is also a Statter, so CreateS3File looks files up with
+// Stat and fills in the found file's Object. That means a HEAD request through the aws sdk client
+// for the bucket's region rather than pathio, which is why it's opt-in rather than S3PathChecker's
+// behavior. A HEAD of a missing object is a 404 either way, which isn't an error for CreateS3File.
+type S3StatPathChecker struct {
+	S3PathChecker
+}
+
+// Stat looks up the object's metadata with a HEAD request
+func (S3StatPathChecker) Stat(path string) (S3ObjectInfo, error) {
+	bucket, _, err := splitS3Path(path)
+	if err != nil {
+		return S3ObjectInfo{}, err
+	}
+	client, err := s3ClientForBucket(bucket)
+	if err != nil {
+		return S3ObjectInfo{}, err
+	}
+	return headObject(client, path)
+}
+
+func headObject(client headObjectAPI, path string) (S3ObjectInfo, error) {
+	bucket, key, err := splitS3Path(path)
+	if err != nil {
+		return S3ObjectInfo{}, err
+	}
+	resp, err := client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return S3ObjectInfo{}, err
+	}
+	return S3ObjectInfo{
+		Size:         aws.Int64Value(resp.ContentLength),
+		LastModified: aws.TimeValue(resp.LastModified),
+		// S3 returns the ETag quoted
+		ETag: strings.Trim(aws.StringValue(resp.ETag), `"`),
+	}, nil
+}
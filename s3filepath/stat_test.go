@@ -0,0 +1,115 @@
+package s3filepath
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockStatter has metadata for the paths that exist
+type mockStatter struct {
+	objects map[string]S3ObjectInfo
+	calls   int
+}
+
+func (ms *mockStatter) FileExists(path string) bool {
+	_, err := ms.Stat(path)
+	return err == nil
+}
+
+func (ms *mockStatter) Stat(path string) (S3ObjectInfo, error) {
+	ms.calls++
+	info, ok := ms.objects[path]
+	if !ok {
+		return S3ObjectInfo{}, awserr.NewRequestFailure(awserr.New("NotFound", "not found", nil), 404, "id")
+	}
+	return info, nil
+}
+
+type mockHeadObject struct {
+	input  *s3.HeadObjectInput
+	output *s3.HeadObjectOutput
+	err    error
+}
+
+func (m *mockHeadObject) HeadObject(input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	m.input = input
+	return m.output, m.err
+}
+
+func TestCreateS3FileStat(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	jsonPath := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z.json"
+	info := S3ObjectInfo{Size: 1024, LastModified: expectedDate, ETag: "abc"}
+	pc := &mockStatter{objects: map[string]S3ObjectInfo{jsonPath: info}}
+
+	f, err := CreateS3File(pc, bucket, "s", "t", "", expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, info, f.Object)
+	// manifest, json.gz and json, plus the two config files
+	assert.Equal(t, 5, pc.calls)
+
+	files, err := FindS3Files(pc, bucket, "s", "t", expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, info, files[0].Object)
+
+	// files found without a Statter don't have metadata
+	f, err = CreateS3File(MockPathChecker{map[string]bool{jsonPath: true}}, bucket, "s", "t", "", expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, S3ObjectInfo{}, f.Object)
+}
+
+func TestHeadObject(t *testing.T) {
+	lastModified := time.Date(2015, time.November, 11, 1, 0, 0, 0, time.UTC)
+	client := &mockHeadObject{output: &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(2048),
+		LastModified:  aws.Time(lastModified),
+		ETag:          aws.String(`"d41d8cd98f00b204e9800998ecf8427e"`),
+	}}
+	info, err := headObject(client, "s3://b/s/t/file.json")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", aws.StringValue(client.input.Bucket))
+	assert.Equal(t, "s/t/file.json", aws.StringValue(client.input.Key))
+	assert.Equal(t, S3ObjectInfo{Size: 2048, LastModified: lastModified, ETag: "d41d8cd98f00b204e9800998ecf8427e"}, info)
+
+	client.err = awserr.NewRequestFailure(awserr.New("NotFound", "not found", nil), 404, "id")
+	_, err = headObject(client, "s3://b/s/t/file.json")
+	assert.True(t, isNotFound(err))
+
+	_, err = headObject(client, "b/s/t/file.json")
+	assert.Error(t, err)
+}
+
+func TestLoadToken(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	jsonPath := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z.json"
+
+	for _, etag := range []string{
+		// single part uploads are the MD5 of the content
+		"d41d8cd98f00b204e9800998ecf8427e",
+		// multipart uploads have the number of parts after a dash
+		"9b2cf535f27731c974343645a3985328-12",
+	} {
+		pc := &mockStatter{objects: map[string]S3ObjectInfo{jsonPath: {Size: 10, ETag: etag}}}
+		f, err := CreateS3File(pc, bucket, "s", "t", "", expectedDate)
+		assert.NoError(t, err)
+		assert.Equal(t, etag, f.Object.ETag)
+		assert.Equal(t, jsonPath+"@"+etag, f.LoadToken())
+	}
+
+	// the same path with different content gets a different token
+	f := buildS3File(bucket, "s", "t", "", expectedDate, "json")
+	f.Object.ETag = "a"
+	other := *f
+	other.Object.ETag = "b"
+	assert.NotEqual(t, f.LoadToken(), other.LoadToken())
+
+	// without a Statter there's no ETag
+	f, err := CreateS3File(MockPathChecker{map[string]bool{jsonPath: true}}, bucket, "s", "t", "", expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, jsonPath, f.LoadToken())
+}
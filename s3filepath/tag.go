@@ -0,0 +1,83 @@
+package s3filepath
+
+import (
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Tags MarkProcessed puts on data files
+const (
+	TagProcessed   = "processed"
+	TagProcessedAt = "processed_at"
+)
+
+// Tagger sets tags on files
+type Tagger interface {
+	// Tag replaces the file's tags with tags
+	Tag(path string, tags map[string]string) error
+}
+
+// S3Tagger tags objects in S3
+type S3Tagger struct{}
+
+// putObjectTaggingAPI is the part of the s3 client needed for Tag
+type putObjectTaggingAPI interface {
+	PutObjectTagging(*s3.PutObjectTaggingInput) (*s3.PutObjectTaggingOutput, error)
+}
+
+// Tag replaces the object's tag set. S3 allows at most 10 tags per object.
+func (S3Tagger) Tag(path string, tags map[string]string) error {
+	bucket, _, err := splitS3Path(path)
+	if err != nil {
+		return err
+	}
+	client, err := s3ClientForBucket(bucket)
+	if err != nil {
+		return err
+	}
+	return putObjectTagging(client, path, tags)
+}
+
+func putObjectTagging(client putObjectTaggingAPI, path string, tags map[string]string) error {
+	bucket, key, err := splitS3Path(path)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	// sorted so the requests are the same for the same tags
+	sort.Strings(keys)
+	tagSet := make([]*s3.Tag, 0, len(keys))
+	for _, k := range keys {
+		tagSet = append(tagSet, &s3.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+	}
+	_, err = client.PutObjectTagging(&s3.PutObjectTaggingInput{
+		Bucket:  aws.String(bucket),
+		Key:     aws.String(key),
+		Tagging: &s3.Tagging{TagSet: tagSet},
+	})
+	return err
+}
+
+// MarkProcessed tags the S3File's data file as loaded, for teams that keep loaded files in place
+// instead of using Archive or Delete. It sets processed=true and processed_at to the current UTC
+// time in RFC 3339, plus the extra tags. The standard tags win over extras with the same key.
+// This replaces any tags the file already had.
+func (f *S3File) MarkProcessed(t Tagger, extra map[string]string) error {
+	return f.markProcessed(t, extra, time.Now())
+}
+
+func (f *S3File) markProcessed(t Tagger, extra map[string]string, now time.Time) error {
+	tags := make(map[string]string, len(extra)+2)
+	for k, v := range extra {
+		tags[k] = v
+	}
+	tags[TagProcessed] = "true"
+	tags[TagProcessedAt] = now.UTC().Format(time.RFC3339)
+	return t.Tag(f.GetDataFilename(), tags)
+}
@@ -0,0 +1,73 @@
+package s3filepath
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+// MockTagger keeps the tags in memory
+type MockTagger struct {
+	Tags map[string]map[string]string
+	Err  error
+}
+
+func (m *MockTagger) Tag(path string, tags map[string]string) error {
+	if m.Err != nil {
+		return m.Err
+	}
+	if m.Tags == nil {
+		m.Tags = map[string]map[string]string{}
+	}
+	m.Tags[path] = tags
+	return nil
+}
+
+func TestMarkProcessed(t *testing.T) {
+	f := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json")
+	now := time.Date(2015, 11, 11, 1, 2, 3, 0, time.FixedZone("PST", -8*3600))
+
+	tagger := &MockTagger{}
+	assert.NoError(t, f.markProcessed(tagger, map[string]string{"team": "data", TagProcessed: "false"}, now))
+	assert.Equal(t, map[string]map[string]string{
+		f.GetDataFilename(): {
+			"processed":    "true",
+			"processed_at": "2015-11-11T09:02:03Z",
+			"team":         "data",
+		},
+	}, tagger.Tags)
+
+	assert.NoError(t, f.MarkProcessed(tagger, nil))
+	processedAt, err := time.Parse(time.RFC3339, tagger.Tags[f.GetDataFilename()][TagProcessedAt])
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), processedAt, time.Minute)
+	assert.Len(t, tagger.Tags[f.GetDataFilename()], 2)
+
+	assert.EqualError(t, f.MarkProcessed(&MockTagger{Err: errors.New("denied")}, nil), "denied")
+}
+
+type mockPutObjectTagging struct {
+	input *s3.PutObjectTaggingInput
+}
+
+func (m *mockPutObjectTagging) PutObjectTagging(input *s3.PutObjectTaggingInput) (*s3.PutObjectTaggingOutput, error) {
+	m.input = input
+	return &s3.PutObjectTaggingOutput{}, nil
+}
+
+func TestPutObjectTagging(t *testing.T) {
+	m := &mockPutObjectTagging{}
+	assert.NoError(t, putObjectTagging(m, "s3://b/s/t/file.json", map[string]string{"z": "1", "a": "2"}))
+	assert.Equal(t, "b", aws.StringValue(m.input.Bucket))
+	assert.Equal(t, "s/t/file.json", aws.StringValue(m.input.Key))
+	assert.Equal(t, []*s3.Tag{
+		{Key: aws.String("a"), Value: aws.String("2")},
+		{Key: aws.String("z"), Value: aws.String("1")},
+	}, m.input.Tagging.TagSet)
+
+	assert.Error(t, putObjectTagging(m, "b/key", nil))
+}
@@ -0,0 +1,53 @@
+package s3filepath
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UnloadOptions control the UNLOAD command generated for an S3File
+type UnloadOptions struct {
+	// Gzip compresses each unloaded file
+	Gzip bool
+	// ParallelOff writes the data serially, to as few files as possible, instead of
+	// one or more files per slice. Redshift unloads in parallel by default.
+	ParallelOff bool
+	// Manifest also writes a manifest listing the unloaded files, at the prefix followed by "manifest"
+	Manifest bool
+}
+
+// GetUnloadPrefix returns the prefix an UNLOAD to the S3File writes to. Redshift appends
+// the part number to it, e.g. s3://bucket/subfolder/schema_table_date_0000_part_00.
+func (f *S3File) GetUnloadPrefix() string {
+	return f.GetDataPrefix() + "_"
+}
+
+// UnloadCommand returns the Redshift UNLOAD command that exports the results of selectSQL
+// to the S3File's unload prefix, so exported data follows the same path conventions as the
+// data we load. The IAM role and region come from the file's bucket.
+func (f *S3File) UnloadCommand(selectSQL string, opts UnloadOptions) (string, error) {
+	if f.Bucket.RedshiftRoleARN == "" {
+		return "", fmt.Errorf("bucket %s has no redshift role ARN to UNLOAD with", f.Bucket.Name)
+	}
+	if strings.TrimSpace(selectSQL) == "" {
+		return "", fmt.Errorf("a select statement is needed to UNLOAD")
+	}
+
+	sql := []string{
+		fmt.Sprintf("UNLOAD (%s) TO %s", quoteString(selectSQL), quoteString(f.GetUnloadPrefix())),
+		fmt.Sprintf("IAM_ROLE %s", quoteString(f.Bucket.RedshiftRoleARN)),
+	}
+	if f.Bucket.Region != "" {
+		sql = append(sql, fmt.Sprintf("REGION %s", quoteString(f.Bucket.Region)))
+	}
+	if opts.Manifest {
+		sql = append(sql, "MANIFEST")
+	}
+	if opts.Gzip {
+		sql = append(sql, "GZIP")
+	}
+	if opts.ParallelOff {
+		sql = append(sql, "PARALLEL OFF")
+	}
+	return strings.Join(sql, " "), nil
+}
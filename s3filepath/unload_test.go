@@ -0,0 +1,35 @@
+package s3filepath
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnloadCommand(t *testing.T) {
+	f := buildS3File(testCopyBucket, "s", "t", "", expectedDate, "")
+	prefix := "s3://b/s/t/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t_2015-11-10T23:00:00Z_"
+	assert.Equal(t, prefix, f.GetUnloadPrefix())
+
+	auth := "IAM_ROLE 'arn:aws:iam::123456789012:role/redshift' REGION 'us-west-2'"
+	for _, test := range []struct {
+		opts     UnloadOptions
+		expected string
+	}{
+		{UnloadOptions{Gzip: true, Manifest: true},
+			`UNLOAD ('SELECT * FROM s.t WHERE name = ''x''') TO '` + prefix + `' ` + auth + ` MANIFEST GZIP`},
+		{UnloadOptions{},
+			`UNLOAD ('SELECT * FROM s.t WHERE name = ''x''') TO '` + prefix + `' ` + auth},
+		{UnloadOptions{ParallelOff: true},
+			`UNLOAD ('SELECT * FROM s.t WHERE name = ''x''') TO '` + prefix + `' ` + auth + ` PARALLEL OFF`},
+	} {
+		sql, err := f.UnloadCommand("SELECT * FROM s.t WHERE name = 'x'", test.opts)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, sql)
+	}
+
+	_, err := f.UnloadCommand(" ", UnloadOptions{})
+	assert.Error(t, err)
+	_, err = buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "").UnloadCommand("SELECT 1", UnloadOptions{})
+	assert.Error(t, err)
+}
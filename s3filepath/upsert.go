@@ -0,0 +1,77 @@
+package s3filepath
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UpsertPlan loads an S3File into a table idempotently: rows with the same primary keys as rows
+// in the file are replaced rather than duplicated. It does this with the usual Redshift staging
+// table merge, see: https://docs.aws.amazon.com/redshift/latest/dg/merge-replacing-existing-rows.html
+type UpsertPlan struct {
+	File *S3File
+	// Target is the table to upsert into. It may be schema qualified; if it's empty
+	// the file's schema and table are used.
+	Target string
+	// PrimaryKeys are the columns that identify a row. There must be at least one.
+	PrimaryKeys []string
+	// CopyOptions are used to COPY the file into the staging table
+	CopyOptions CopyOptions
+	// Staging is the name of the temporary staging table. Defaults to the target's table name
+	// with a _staging suffix.
+	Staging string
+}
+
+// Statements returns the SQL to run, in order: it begins a transaction, creates a temporary
+// staging table like the target, COPYs the file into it, deletes the target's rows that are
+// in the staging table, inserts all the staging table's rows into the target, drops the
+// staging table and commits.
+func (p UpsertPlan) Statements() ([]string, error) {
+	statements, err := p.mergeStatements()
+	if err != nil {
+		return nil, err
+	}
+	return inTransaction(statements), nil
+}
+
+// mergeStatements are the Statements without the BEGIN and COMMIT, so they can go in a LoadPlan
+func (p UpsertPlan) mergeStatements() ([]string, error) {
+	if p.File == nil {
+		return nil, fmt.Errorf("upsert plan has no file")
+	}
+	if len(p.PrimaryKeys) == 0 {
+		return nil, fmt.Errorf("upsert plan needs at least one primary key column")
+	}
+	target := p.Target
+	if target == "" {
+		target = p.File.Schema + "." + p.File.Table
+	}
+	staging := p.Staging
+	if staging == "" {
+		parts := strings.Split(target, ".")
+		staging = parts[len(parts)-1] + "_staging"
+	}
+
+	copySQL, err := p.File.CopyCommand(staging, p.CopyOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	quotedTarget, quotedStaging := quoteTableName(target), quoteTableName(staging)
+	var keys []string
+	for _, key := range p.PrimaryKeys {
+		keys = append(keys, fmt.Sprintf("%s.%s = %s.%s", quotedTarget, quoteIdent(key), quotedStaging, quoteIdent(key)))
+	}
+	return []string{
+		fmt.Sprintf("CREATE TEMP TABLE %s (LIKE %s)", quotedStaging, quotedTarget),
+		copySQL,
+		fmt.Sprintf("DELETE FROM %s USING %s WHERE %s", quotedTarget, quotedStaging, strings.Join(keys, " AND ")),
+		fmt.Sprintf("INSERT INTO %s SELECT * FROM %s", quotedTarget, quotedStaging),
+		fmt.Sprintf("DROP TABLE %s", quotedStaging),
+	}, nil
+}
+
+// inTransaction wraps the statements in BEGIN and COMMIT
+func inTransaction(statements []string) []string {
+	return append(append([]string{"BEGIN"}, statements...), "COMMIT")
+}
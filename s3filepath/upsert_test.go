@@ -0,0 +1,54 @@
+package s3filepath
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpsertPlan(t *testing.T) {
+	f := buildS3File(testCopyBucket, "s", "t", "", expectedDate, "json.gz")
+	statements, err := UpsertPlan{File: f, PrimaryKeys: []string{"id", "day"}}.Statements()
+	assert.NoError(t, err)
+
+	copySQL, err := f.CopyCommand("t_staging", CopyOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"BEGIN",
+		`CREATE TEMP TABLE "t_staging" (LIKE "s"."t")`,
+		copySQL,
+		`DELETE FROM "s"."t" USING "t_staging" WHERE "s"."t"."id" = "t_staging"."id" AND "s"."t"."day" = "t_staging"."day"`,
+		`INSERT INTO "s"."t" SELECT * FROM "t_staging"`,
+		`DROP TABLE "t_staging"`,
+		"COMMIT",
+	}, statements)
+	assert.Contains(t, copySQL, `COPY "t_staging" FROM`)
+
+	// a custom target, staging table and copy options
+	statements, err = UpsertPlan{
+		File:        f,
+		Target:      "other.events",
+		PrimaryKeys: []string{"id"},
+		CopyOptions: CopyOptions{MaxError: 5},
+		Staging:     "events_load",
+	}.Statements()
+	assert.NoError(t, err)
+	assert.Equal(t, `CREATE TEMP TABLE "events_load" (LIKE "other"."events")`, statements[1])
+	assert.Contains(t, statements[2], `COPY "events_load" FROM`)
+	assert.Contains(t, statements[2], "MAXERROR 5")
+	assert.Equal(t, `DELETE FROM "other"."events" USING "events_load" WHERE "other"."events"."id" = "events_load"."id"`, statements[3])
+
+	// keys are quoted like any other name
+	statements, err = UpsertPlan{File: f, Target: "s.t", PrimaryKeys: []string{`k"ey`}, Staging: "t_staging"}.Statements()
+	assert.NoError(t, err)
+	assert.Equal(t, `DELETE FROM "s"."t" USING "t_staging" WHERE "s"."t"."k""ey" = "t_staging"."k""ey"`, statements[3])
+
+	_, err = UpsertPlan{File: f}.Statements()
+	assert.Error(t, err)
+	_, err = UpsertPlan{PrimaryKeys: []string{"id"}}.Statements()
+	assert.Error(t, err)
+	// copy errors are passed on
+	noRole := buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json.gz")
+	_, err = UpsertPlan{File: noRole, PrimaryKeys: []string{"id"}}.Statements()
+	assert.Error(t, err)
+}
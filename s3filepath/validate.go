@@ -0,0 +1,100 @@
+package s3filepath
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+var (
+	// e.g. us-west-2, eu-central-1, us-gov-west-1
+	regionRegex  = regexp.MustCompile(`^[a-z]{2}(-gov)?-[a-z]+-\d+$`)
+	roleARNRegex = regexp.MustCompile(`^arn:aws:iam::\d+:role/.+$`)
+	nameRegex    = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+	// separators can't be letters or numbers, they'd be confused with the names and dates
+	separatorRegex = regexp.MustCompile(`^[^A-Za-z0-9/]+$`)
+)
+
+// Validate checks that the bucket has everything a COPY needs: a name, a region
+// and a Redshift IAM role ARN. Every offending field is included in the error.
+func (b S3Bucket) Validate() error {
+	var errors error
+	if b.Name == "" {
+		errors = multierror.Append(errors, fmt.Errorf("bucket Name must not be empty"))
+	}
+	if !regionRegex.MatchString(b.Region) {
+		errors = multierror.Append(errors, fmt.Errorf("bucket Region %q is not an AWS region", b.Region))
+	}
+	if !roleARNRegex.MatchString(b.RedshiftRoleARN) {
+		errors = multierror.Append(errors, fmt.Errorf("bucket RedshiftRoleARN %q is not an IAM role ARN", b.RedshiftRoleARN))
+	}
+	if s := b.scheme(); s != SchemeS3 && s != SchemeGCS {
+		errors = multierror.Append(errors, fmt.Errorf("bucket Scheme %q must be %q or %q", b.Scheme, SchemeS3, SchemeGCS))
+	}
+	if err := b.Encryption().Validate(); err != nil {
+		errors = multierror.Append(errors, fmt.Errorf("bucket %s", err))
+	}
+	return errors
+}
+
+// validateName checks that a schema or table name only has letters, numbers and underscores.
+// Anything else could build a key outside the table's own prefix, e.g. with a slash or "..".
+func validateName(kind, name string) error {
+	if !nameRegex.MatchString(name) {
+		return fmt.Errorf("invalid %s name %q: must only contain letters, numbers and underscores", kind, name)
+	}
+	return nil
+}
+
+func validateSeparator(separator string) error {
+	if !separatorRegex.MatchString(separator) {
+		return fmt.Errorf("invalid filename separator %q: must not be empty or contain letters, numbers or slashes", separator)
+	}
+	return nil
+}
+
+func validateNames(schema, table string) error {
+	if err := validateName("schema", schema); err != nil {
+		return err
+	}
+	return validateName("table", table)
+}
+
+// Validate checks that the S3File has a valid schema and table name, and a date.
+// It doesn't validate the bucket, see S3Bucket.Validate for that.
+func (f *S3File) Validate() error {
+	var errors error
+	if f.Schema == "" {
+		errors = multierror.Append(errors, fmt.Errorf("s3 file Schema must not be empty"))
+	} else if err := validateName("schema", f.Schema); err != nil {
+		errors = multierror.Append(errors, fmt.Errorf("s3 file Schema: %s", err))
+	}
+	if f.Table == "" {
+		errors = multierror.Append(errors, fmt.Errorf("s3 file Table must not be empty"))
+	} else if err := validateName("table", f.Table); err != nil {
+		errors = multierror.Append(errors, fmt.Errorf("s3 file Table: %s", err))
+	}
+	if f.DataDate.IsZero() {
+		errors = multierror.Append(errors, fmt.Errorf("s3 file DataDate must not be zero"))
+	}
+	return errors
+}
+
+// CreateValidS3File is CreateS3File, but it validates the bucket and the file first
+// so a missing role or region fails here instead of later at Redshift.
+func CreateValidS3File(pc PathChecker, bucket S3Bucket, schema, table, suppliedConf string, date time.Time) (*S3File, error) {
+	var errors error
+	if err := bucket.Validate(); err != nil {
+		errors = multierror.Append(errors, err)
+	}
+	if err := buildS3File(bucket, schema, table, suppliedConf, date, "").Validate(); err != nil {
+		errors = multierror.Append(errors, err)
+	}
+	if errors != nil {
+		return nil, errors
+	}
+	return CreateS3FileContext(context.Background(), pc, bucket, schema, table, suppliedConf, date)
+}
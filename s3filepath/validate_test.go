@@ -0,0 +1,97 @@
+package s3filepath
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestS3BucketValidate(t *testing.T) {
+	assert.NoError(t, S3Bucket{Name: "b", Region: "us-west-2", RedshiftRoleARN: "arn:aws:iam::123456789012:role/redshift"}.Validate())
+	assert.NoError(t, S3Bucket{Name: "b", Region: "us-gov-west-1", RedshiftRoleARN: "arn:aws:iam::123456789012:role/path/redshift"}.Validate())
+
+	err := S3Bucket{}.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Name")
+	assert.Contains(t, err.Error(), "Region")
+	assert.Contains(t, err.Error(), "RedshiftRoleARN")
+
+	err = S3Bucket{Name: "b", Region: "west", RedshiftRoleARN: "arn:aws:iam::123456789012:user/redshift"}.Validate()
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "Name")
+	assert.Contains(t, err.Error(), `Region "west"`)
+	assert.Contains(t, err.Error(), "RedshiftRoleARN")
+}
+
+func TestS3BucketValidateEncryption(t *testing.T) {
+	bucket := S3Bucket{Name: "b", Region: "us-west-2", RedshiftRoleARN: "arn:aws:iam::123456789012:role/redshift"}
+	for _, e := range []Encryption{{}, {SSEMode: SSEModeAES256}, {SSEMode: SSEModeKMS}, {SSEMode: SSEModeKMS, KMSKeyID: "k"}} {
+		bucket.SSEMode, bucket.KMSKeyID = e.SSEMode, e.KMSKeyID
+		assert.NoError(t, bucket.Validate(), "%+v", e)
+	}
+
+	bucket.SSEMode, bucket.KMSKeyID = "kms", ""
+	assert.Error(t, bucket.Validate())
+	bucket.SSEMode, bucket.KMSKeyID = "", "k"
+	assert.Error(t, bucket.Validate())
+}
+
+func TestS3FileValidate(t *testing.T) {
+	assert.NoError(t, buildS3File(S3Bucket{Name: "b"}, "s", "t", "", expectedDate, "json").Validate())
+
+	err := (&S3File{}).Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Schema")
+	assert.Contains(t, err.Error(), "Table")
+	assert.Contains(t, err.Error(), "DataDate")
+}
+
+func TestCreateValidS3File(t *testing.T) {
+	bucket := S3Bucket{Name: "b", Region: "us-west-2", RedshiftRoleARN: "arn:aws:iam::123456789012:role/redshift"}
+	f := buildS3File(bucket, "bar", "foo", "", expectedDate, "json")
+	pc := MockPathChecker{map[string]bool{f.GetDataFilename(): true}}
+	created, err := CreateValidS3File(pc, bucket, "bar", "foo", "", expectedDate)
+	assert.NoError(t, err)
+	assert.Equal(t, "json", created.Suffix)
+
+	// a bad bucket and a bad file are reported together, before looking anything up
+	_, err = CreateValidS3File(pc, S3Bucket{Name: "b"}, "", "foo", "", time.Time{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Region")
+	assert.Contains(t, err.Error(), "Schema")
+	assert.Contains(t, err.Error(), "DataDate")
+}
+
+func TestInvalidNames(t *testing.T) {
+	bucket := S3Bucket{Name: "b"}
+	pc := MockPathChecker{map[string]bool{}}
+	for _, name := range []string{"a/b", "..", "../other", "a.b", "a b", ""} {
+		for _, names := range [][2]string{{name, "t"}, {"s", name}} {
+			schema, table := names[0], names[1]
+			_, err := NewS3File(bucket, schema, table, WithDate(expectedDate))
+			assert.Error(t, err, name)
+			_, err = CreateS3File(pc, bucket, schema, table, "", expectedDate)
+			assert.Error(t, err, name)
+			assert.False(t, errors.Is(err, ErrFileNotFound), name)
+			_, err = FindS3Files(pc, bucket, schema, table, expectedDate)
+			assert.Error(t, err, name)
+			_, err = WaitForFile(context.Background(), pc, bucket, schema, table, expectedDate, time.Hour)
+			assert.Error(t, err, name)
+		}
+	}
+
+	_, err := CreateS3File(pc, bucket, "my/schema", "t", "", expectedDate)
+	assert.EqualError(t, err, `invalid schema name "my/schema": must only contain letters, numbers and underscores`)
+	_, err = ParseDataFilename("s3://b/s/t.x/_data_timestamp_year=2015/_data_timestamp_month=11/_data_timestamp_day=10/s_t.x_2015-11-10T23:00:00Z.json")
+	assert.Error(t, err)
+
+	err = (&S3File{Schema: "s", Table: "a/b", DataDate: expectedDate}).Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Table")
+
+	_, err = NewS3File(bucket, "Order_Line_Items2", "t", WithDate(expectedDate))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,75 @@
+package s3filepath
+
+import (
+	"bytes"
+
+	"github.com/Clever/pathio"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Writer writes files, replacing them if they already exist
+type Writer interface {
+	Write(path string, data []byte) error
+}
+
+// S3Writer writes files to S3 with its Encryption
+type S3Writer struct {
+	Encryption
+}
+
+// NewS3Writer returns an S3Writer that writes with the bucket's encryption settings
+func NewS3Writer(bucket S3Bucket) S3Writer {
+	return S3Writer{Encryption: bucket.Encryption()}
+}
+
+// FileExists is S3PathChecker's, so writes that mustn't replace a file can check for it first
+func (S3Writer) FileExists(path string) bool {
+	return S3PathChecker{}.FileExists(path)
+}
+
+// FileExistsErr is S3PathChecker's
+func (S3Writer) FileExistsErr(path string) (bool, error) {
+	return S3PathChecker{}.FileExistsErr(path)
+}
+
+// putObjectAPI is the part of the s3 client needed for encrypted writes
+type putObjectAPI interface {
+	PutObject(*s3.PutObjectInput) (*s3.PutObjectOutput, error)
+}
+
+// Write uploads the data to the path. Without any Encryption it uses pathio.Write,
+// which can't set the encryption, so encrypted writes use PutObject directly.
+func (w S3Writer) Write(path string, data []byte) error {
+	if w.Encryption.IsZero() {
+		return pathio.Write(path, data)
+	}
+	if err := w.Encryption.Validate(); err != nil {
+		return err
+	}
+	bucket, _, err := splitS3Path(path)
+	if err != nil {
+		return err
+	}
+	client, err := s3ClientForBucket(bucket)
+	if err != nil {
+		return err
+	}
+	return putObject(client, path, data, w.Encryption)
+}
+
+func putObject(client putObjectAPI, path string, data []byte, encryption Encryption) error {
+	bucket, key, err := splitS3Path(path)
+	if err != nil {
+		return err
+	}
+	sse, kmsKeyID := encryption.params()
+	_, err = client.PutObject(&s3.PutObjectInput{
+		Bucket:               aws.String(bucket),
+		Key:                  aws.String(key),
+		Body:                 bytes.NewReader(data),
+		ServerSideEncryption: sse,
+		SSEKMSKeyId:          kmsKeyID,
+	})
+	return err
+}
@@ -0,0 +1,80 @@
+package s3filepath
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+// MockWriter keeps the files written to it in memory
+type MockWriter struct {
+	Files map[string][]byte
+	Err   error
+}
+
+func (mw *MockWriter) Write(path string, data []byte) error {
+	if mw.Err != nil {
+		return mw.Err
+	}
+	if mw.Files == nil {
+		mw.Files = map[string][]byte{}
+	}
+	mw.Files[path] = data
+	return nil
+}
+
+func (mw *MockWriter) FileExists(path string) bool {
+	_, ok := mw.Files[path]
+	return ok
+}
+
+func TestS3WriterLocal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3filepath")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// pathio writes non-s3 paths to the local filesystem
+	p := filepath.Join(dir, "file.json")
+	assert.NoError(t, S3Writer{}.Write(p, []byte("{}")))
+	data, err := ioutil.ReadFile(p)
+	assert.NoError(t, err)
+	assert.Equal(t, "{}", string(data))
+}
+
+type mockPutObject struct {
+	input *s3.PutObjectInput
+	body  []byte
+}
+
+func (m *mockPutObject) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	m.input = input
+	m.body, _ = ioutil.ReadAll(input.Body)
+	return &s3.PutObjectOutput{}, nil
+}
+
+func TestPutObject(t *testing.T) {
+	m := &mockPutObject{}
+	assert.NoError(t, putObject(m, "s3://b/s/t/file.manifest", []byte("{}"), Encryption{}))
+	assert.Equal(t, "b", aws.StringValue(m.input.Bucket))
+	assert.Equal(t, "s/t/file.manifest", aws.StringValue(m.input.Key))
+	assert.Equal(t, "{}", string(m.body))
+	assert.Nil(t, m.input.ServerSideEncryption)
+	assert.Nil(t, m.input.SSEKMSKeyId)
+
+	w := NewS3Writer(S3Bucket{Name: "b", SSEMode: SSEModeKMS, KMSKeyID: "key-id"})
+	assert.NoError(t, putObject(m, "s3://b/s/t/file.manifest", []byte("{}"), w.Encryption))
+	assert.Equal(t, "aws:kms", aws.StringValue(m.input.ServerSideEncryption))
+	assert.Equal(t, "key-id", aws.StringValue(m.input.SSEKMSKeyId))
+
+	assert.Error(t, putObject(m, "b/s/t/file.manifest", nil, Encryption{}))
+}
+
+func TestS3WriterEncryptionValidated(t *testing.T) {
+	w := S3Writer{Encryption{SSEMode: "aws:fake"}}
+	assert.EqualError(t, w.Write("s3://b/k", nil), `SSEMode "aws:fake" must be "AES256" or "aws:kms"`)
+}